@@ -0,0 +1,96 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"image/png"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// archivePrefix starts an -output that names a zip archive for txt and png
+// puzzles, instead of writing them to -out_dir.
+const archivePrefix = "archive:"
+
+// A zipWriter writes each puzzle, in each of its formats, as an entry in a
+// zip archive, named as it would be in -out_dir. Entries are rendered
+// concurrently, but only one is added to the archive at a time.
+type zipWriter struct {
+	formats []string
+	shard   bool
+	txt     *txtWriter // for the layout of txt entries
+
+	mu sync.Mutex
+	f  *os.File
+	zw *zip.Writer
+}
+
+// newZipWriter creates the archive at path for puzzles in formats, each of
+// which must be txt or png.
+func newZipWriter(path string, formats []string) (*zipWriter, error) {
+	for _, f := range formats {
+		if f != "txt" && f != "png" {
+			return nil, fmt.Errorf("format %q can't be written to an archive, want txt or png", f)
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("Create(%q): %w", path, err)
+	}
+	txt := newTxtWriter("", false, *txtHeader, *scoreFirst, 1)
+	if *markPangrams {
+		txt.mark = *pangramMark
+	}
+	return &zipWriter{formats: formats, shard: *shardOutputByCenter, txt: txt, f: f, zw: zip.NewWriter(f)}, nil
+}
+
+func (w *zipWriter) write(_ context.Context, p puzzle) (string, error) {
+	names := make([]string, len(w.formats))
+	bodies := make([]bytes.Buffer, len(w.formats))
+	for i, f := range w.formats {
+		names[i] = p.letters + "." + f
+		if w.shard {
+			names[i] = path.Join(centerLetter(p.letters), names[i])
+		}
+		switch f {
+		case "txt":
+			b := bufio.NewWriter(&bodies[i])
+			w.txt.writeTo(b, p)
+			if err := b.Flush(); err != nil {
+				return "", err
+			}
+		case "png":
+			if err := png.Encode(&bodies[i], renderHive(p.letters, pngSize)); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i, name := range names {
+		e, err := w.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return "", err
+		}
+		if _, err := bodies[i].WriteTo(e); err != nil {
+			return "", err
+		}
+	}
+	return names[0], nil
+}
+
+func (w *zipWriter) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.zw.Close(); err != nil {
+		w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestZipArchive(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	setFlag(t, shardOutputByCenter, true)
+	setFlag(t, v, false)
+	setFlag(t, outDir, t.TempDir())
+	path := filepath.Join(t.TempDir(), "puzzles.zip")
+	setFlag(t, output, archivePrefix+path)
+	setFlag(t, format, "txt,png")
+	pw, err := newPuzzleWriter()
+	if err != nil {
+		t.Fatalf("newPuzzleWriter: %v", err)
+	}
+	ps := manyPuzzles(20)
+	in := make(chan puzzle, len(ps))
+	for _, p := range ps {
+		in <- p
+	}
+	close(in)
+	if _, err := writePuzzles(context.Background(), in, pw); err != nil {
+		t.Fatalf("writePuzzles: %v", err)
+	}
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	names := []string{}
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if len(names) != 2*len(ps) || !slices.Contains(names, "a/aelprst0.txt") || !slices.Contains(names, "a/aelprst0.png") {
+		t.Fatalf("the archive has %d entries, %q; want a txt and a png, by center, for each of %d puzzles", len(names), names, len(ps))
+	}
+	f, err := zr.Open("a/aelprst0.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setFlag(t, shardOutputByCenter, false)
+	if want := readOutput(t, writeFormat(t, "txt", ps[0]), "aelprst0.txt"); !bytes.Equal(got, want) {
+		t.Errorf("a/aelprst0.txt in the archive is %q, want %q as in -out_dir", got, want)
+	}
+}
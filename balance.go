@@ -0,0 +1,65 @@
+package main
+
+import "sort"
+
+// balanceCenters reads every puzzle from in and returns a channel of at most
+// n of them, chosen so that each center letter is used as evenly as
+// possible. Each center's puzzles are taken highest scoring first.
+func balanceCenters(in <-chan puzzle, n int) <-chan puzzle {
+	out := make(chan puzzle)
+	go func() {
+		defer close(out)
+		for _, p := range selectBalanced(collect(in), n) {
+			out <- p
+		}
+	}()
+	return out
+}
+
+func collect(in <-chan puzzle) []puzzle {
+	ps := []puzzle{}
+	for p := range in {
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+// selectBalanced picks n of ps in rounds, taking the best remaining puzzle
+// for every center letter in each round, until it has n. Within a round the
+// best puzzles come first, so a final partial round favors no letter by its
+// place in the alphabet.
+func selectBalanced(ps []puzzle, n int) []puzzle {
+	better := func(a, b puzzle) bool {
+		if a.maxPts != b.maxPts {
+			return a.maxPts > b.maxPts
+		}
+		return a.letters < b.letters
+	}
+	byCenter := map[string][]puzzle{}
+	for _, p := range ps {
+		c := centerLetter(p.letters)
+		byCenter[c] = append(byCenter[c], p)
+	}
+	for _, g := range byCenter {
+		sort.Slice(g, func(i, j int) bool { return better(g[i], g[j]) })
+	}
+
+	selected := []puzzle{}
+	for round := 0; len(selected) < n; round++ {
+		next := []puzzle{}
+		for _, g := range byCenter {
+			if round < len(g) {
+				next = append(next, g[round])
+			}
+		}
+		if len(next) == 0 {
+			break
+		}
+		sort.Slice(next, func(i, j int) bool { return better(next[i], next[j]) })
+		if len(next) > n-len(selected) {
+			next = next[:n-len(selected)]
+		}
+		selected = append(selected, next...)
+	}
+	return selected
+}
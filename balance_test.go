@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// centerPuzzles returns n puzzles for each center letter in counts, worth
+// from 1 to n points.
+func centerPuzzles(counts map[string]int) chan puzzle {
+	ps := make(chan puzzle, 100)
+	for c, n := range counts {
+		for i := 1; i <= n; i++ {
+			ps <- puzzle{letters: fmt.Sprintf("%s%06d", c, i), maxPts: i}
+		}
+	}
+	close(ps)
+	return ps
+}
+
+func TestBalanceCenters(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	for _, tc := range []struct {
+		counts map[string]int
+		n      int
+		want   map[string]int
+	}{
+		// 10 doesn't divide by 3, so one center gets a puzzle more.
+		{map[string]int{"a": 10, "b": 10, "c": 10}, 10, nil},
+		// c only has one puzzle to give.
+		{map[string]int{"a": 10, "b": 10, "c": 1}, 9, map[string]int{"a": 4, "b": 4, "c": 1}},
+		{map[string]int{"a": 2, "b": 1}, 10, map[string]int{"a": 2, "b": 1}},
+	} {
+		got := map[string]int{}
+		best := map[string]int{}
+		for p := range balanceCenters(centerPuzzles(tc.counts), tc.n) {
+			c := centerLetter(p.letters)
+			got[c]++
+			best[c] = max(best[c], p.maxPts)
+		}
+		if tc.want == nil {
+			lo, hi := tc.n, 0
+			for c := range tc.counts {
+				lo, hi = min(lo, got[c]), max(hi, got[c])
+			}
+			if hi-lo > 1 {
+				t.Errorf("balanceCenters(%v, %d) chose %v, want counts within 1 of each other", tc.counts, tc.n, got)
+			}
+		} else if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("balanceCenters(%v, %d) chose %v, want %v", tc.counts, tc.n, got, tc.want)
+		}
+		for c, n := range tc.counts {
+			if got[c] > 0 && best[c] != n {
+				t.Errorf("balanceCenters(%v, %d) skipped %s's best puzzle", tc.counts, tc.n, c)
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// puzzleCache is an LRU cache of makePuzzle results, keyed by
+// canonicalLetters. It is safe for concurrent use. A cache of size 0 stores
+// nothing.
+type puzzleCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List // of *cacheEntry, most recently used first
+	items map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key string
+	p   puzzle
+	ok  bool
+}
+
+func newPuzzleCache(size int) *puzzleCache {
+	return &puzzleCache{size: size, ll: list.New(), items: map[string]*list.Element{}}
+}
+
+// get returns the cached result of makePuzzle for key, and whether there
+// was one.
+func (c *puzzleCache) get(key string) (p puzzle, ok, found bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, found := c.items[key]
+	if !found {
+		return puzzle{}, false, false
+	}
+	c.ll.MoveToFront(e)
+	cacheHits.Add(1)
+	ce := e.Value.(*cacheEntry)
+	return ce.p, ce.ok, true
+}
+
+// add caches the result of makePuzzle for key, evicting the least recently
+// used entry if the cache is full.
+func (c *puzzleCache) add(key string, p puzzle, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size <= 0 {
+		return
+	}
+	if e, found := c.items[key]; found {
+		c.ll.MoveToFront(e)
+		e.Value = &cacheEntry{key, p, ok}
+		return
+	}
+	c.items[key] = c.ll.PushFront(&cacheEntry{key, p, ok})
+	if c.ll.Len() > c.size {
+		e := c.ll.Back()
+		c.ll.Remove(e)
+		delete(c.items, e.Value.(*cacheEntry).key)
+	}
+}
+
+// clear empties the cache, such as after the dictionary changes.
+func (c *puzzleCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll.Init()
+	c.items = map[string]*list.Element{}
+}
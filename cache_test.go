@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestPuzzleCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newPuzzleCache(2)
+	c.add("aelprst", puzzle{letters: "aelprst"}, true)
+	c.add("bcdeirt", puzzle{}, false)
+	c.get("aelprst")
+	c.add("cdeinor", puzzle{letters: "cdeinor"}, true)
+	if _, _, found := c.get("bcdeirt"); found {
+		t.Error("bcdeirt, the least recently used, is still cached")
+	}
+	for _, key := range []string{"aelprst", "cdeinor"} {
+		if p, ok, found := c.get(key); !found || !ok || p.letters != key {
+			t.Errorf("get(%q) = %q, %t, %t; want the cached puzzle", key, p.letters, ok, found)
+		}
+	}
+
+	c.clear()
+	if _, _, found := c.get("aelprst"); found {
+		t.Error("aelprst is still cached after clear")
+	}
+	empty := newPuzzleCache(0)
+	empty.add("aelprst", puzzle{}, true)
+	if _, _, found := empty.get("aelprst"); found {
+		t.Error("a cache of size 0 stored a puzzle")
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// chain passes consume a chain of n puzzles, each of whose letter sets
+// differs from the one before in exactly one letter, for a game where each
+// day's puzzle follows on from the last. It starts from the first letter set
+// from letterSets that begins a chain, so -letters picks the start and
+// -shuffle_order a random one. Every letter set in the chain is different.
+func chain(ctx context.Context, n int, consume func(<-chan puzzle)) error {
+	idx, err := loadIndex(*wordsFile)
+	if err != nil {
+		return err
+	}
+	setsCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	sets, err := letterSets(setsCtx)
+	if err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	for s := range sets {
+		c := findChain(ctx, idx, s, n, seen)
+		if c == nil {
+			continue
+		}
+		cancel()
+		slog.Info("Found chain", "start", c[0].letters, "end", c[len(c)-1].letters, "puzzles", len(c))
+		out := make(chan puzzle, len(c))
+		for _, p := range c {
+			out <- p
+		}
+		close(out)
+		consume(out)
+		return nil
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("no chain of %d puzzles found before stopping: %w", n, context.Cause(ctx))
+	}
+	return fmt.Errorf("no chain of %d puzzles found", n)
+}
+
+// findChain returns a chain of n puzzles starting with the letter set s, or
+// nil if there isn't one. Each next letter set is found by replacing one
+// letter of the last with another from the alphabet, keeping to
+// -min_vowels and -max_vowels, trying the positions and then the alphabet
+// in order, depth first. Letter sets in seen are
+// skipped, and every set tried is added to it, so no set is checked twice
+// even across calls; this keeps the search short, though it can miss a
+// chain that goes through a set tried earlier.
+func findChain(ctx context.Context, idx *wordIndex, s string, n int, seen map[string]bool) []puzzle {
+	key := canonicalLetters(s)
+	if seen[key] || ctx.Err() != nil {
+		return nil
+	}
+	seen[key] = true
+	p, ok := chainPuzzle(idx, s)
+	if !ok {
+		return nil
+	}
+	if n == 1 {
+		return []puzzle{p}
+	}
+	rs := []rune(s)
+	for i, old := range rs {
+		for _, r := range opts.Alphabet {
+			if strings.ContainsRune(s, r) {
+				continue
+			}
+			rs[i] = r
+			if !vowelsInRange(string(rs)) {
+				continue
+			}
+			if rest := findChain(ctx, idx, string(rs), n-1, seen); rest != nil {
+				return append([]puzzle{p}, rest...)
+			}
+		}
+		rs[i] = old
+	}
+	return nil
+}
+
+// chainPuzzle builds the puzzle for the letter set s and reports whether it
+// passes every check matchWords makes.
+func chainPuzzle(idx *wordIndex, s string) (puzzle, bool) {
+	p, ok := makePuzzle(idx, s)
+	if !ok {
+		return puzzle{}, false
+	}
+	if reason := finalCheck(p); reason != "" {
+		rejectLetters(s, p, reason)
+		return puzzle{}, false
+	}
+	return p, true
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"math/bits"
+	"testing"
+)
+
+func TestChainAdjacency(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	setFlag(t, wordsFile, "dict.txt")
+	setFlag(t, letters, "aelprst")
+	setFlag(t, lettersFile, "")
+	var got []puzzle
+	err := chain(context.Background(), 5, func(in <-chan puzzle) {
+		for p := range in {
+			got = append(got, p)
+		}
+	})
+	if err != nil {
+		t.Fatalf("chain: %v", err)
+	}
+	if len(got) != 5 {
+		t.Fatalf("chain(5) gave %d puzzles", len(got))
+	}
+	idx := testIndex(t, repoDictionary(t)...)
+	seen := map[string]bool{}
+	for i, p := range got {
+		if seen[p.letters] {
+			t.Errorf("%s is in the chain twice", p.letters)
+		}
+		seen[p.letters] = true
+		if _, reason := checkPuzzle(idx, p.letters); reason != "" {
+			t.Errorf("chain puzzle %s isn't a valid puzzle: %s", p.letters, reason)
+		}
+		if i > 0 {
+			if d := bits.OnesCount32(letterMask(p.letters, opts.Alphabet) &^ letterMask(got[i-1].letters, opts.Alphabet)); d != 1 {
+				t.Errorf("%s follows %s but differs in %d letters, want 1", p.letters, got[i-1].letters, d)
+			}
+		}
+	}
+}
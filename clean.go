@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+var cleanFlags = flag.NewFlagSet("clean", flag.ContinueOnError)
+
+var dryRun = cleanFlags.Bool("dry_run", false, "Print the files that would be removed without removing them")
+
+// runClean removes everything matching the glob patterns in args, or the
+// puzzle files in -out_dir, including sharded ones, if no patterns are given.
+func runClean(args []string) error {
+	if len(args) == 0 {
+		args = []string{filepath.Join(*outDir, "*.txt"), filepath.Join(*outDir, "*", "*.txt")}
+	}
+	for _, pattern := range args {
+		if *dryRun {
+			contents, err := filepath.Glob(pattern)
+			if err != nil {
+				return err
+			}
+			for _, item := range contents {
+				fmt.Println(item)
+			}
+			continue
+		}
+		if err := RemoveGlob(pattern); err != nil {
+			return fmt.Errorf("Error removing files: %+v", err)
+		}
+	}
+	if !*dryRun {
+		slog.Info("Removed files")
+	}
+	return nil
+}
+
+func RemoveGlob(path string) (err error) {
+	contents, err := filepath.Glob(path)
+	if err != nil {
+		return
+	}
+	for _, item := range contents {
+		err = os.RemoveAll(item)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// configFile is registered on every subcommand's flag set.
+var configFile = new(string)
+
+// loadConfig sets opts from the JSON object in the file at path, whose keys
+// are Options field names, such as {"MinWords": 20}. Flags set explicitly in
+// fs are applied again afterwards, so they override the file.
+func loadConfig(path string, fs *flag.FlagSet) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return keepingFlags(fs, func() error {
+		dec := json.NewDecoder(f)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&opts); err != nil {
+			return fmt.Errorf("config %s: %v", path, err)
+		}
+		return nil
+	})
+}
+
+// keepingFlags calls change, which sets opts, then sets the flags set
+// explicitly in fs again, so they take precedence over it.
+func keepingFlags(fs *flag.FlagSet, change func() error) error {
+	// Remember the explicit flags first: change overwrites the variables
+	// they are bound to.
+	set := map[string]string{}
+	fs.Visit(func(fl *flag.Flag) { set[fl.Name] = fl.Value.String() })
+	if err := change(); err != nil {
+		return err
+	}
+	for name, value := range set {
+		if err := fs.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+// runCapturingOpts runs args with the named subcommand's handler replaced by
+// one that returns the options it would have run with. The flags are parsed
+// by a copy of the subcommand's flag set, bound to the same variables, so
+// flags set by earlier runs don't count as set explicitly.
+func runCapturingOpts(t *testing.T, name string, args ...string) (Options, error) {
+	t.Helper()
+	setOpts(t, DefaultOptions())
+	var got Options
+	for _, c := range commands {
+		if c.name == name {
+			oldRun, oldFlags := c.run, c.flags
+			c.run = func([]string) error {
+				got = opts
+				return nil
+			}
+			c.flags = flag.NewFlagSet(oldFlags.Name(), flag.ContinueOnError)
+			oldFlags.VisitAll(func(f *flag.Flag) { c.flags.Var(f.Value, f.Name, f.Usage) })
+			defer func() { c.run, c.flags = oldRun, oldFlags }()
+		}
+	}
+	err := run(append([]string{name}, args...))
+	return got, err
+}
+
+func TestConfigFileAndFlagOverrides(t *testing.T) {
+	setFlag(t, configFile, "")
+	path := writeTestFile(t, "config.json", `{"MinWordLen": 4, "NumLetters": 6, "FourLetterScore": 2}`)
+	got, err := runCapturingOpts(t, "rescore", "-num_letters", "5", "-config", path)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got.MinWordLen != 4 || got.FourLetterScore != 2 {
+		t.Errorf("with -config, MinWordLen %d and FourLetterScore %d, want 4 and 2 from the file", got.MinWordLen, got.FourLetterScore)
+	}
+	if got.NumLetters != 5 {
+		t.Errorf("with -config and -num_letters 5, NumLetters is %d, want the flag's 5", got.NumLetters)
+	}
+
+	bad := writeTestFile(t, "bad.json", `{"MinWord": 4}`)
+	if _, err := runCapturingOpts(t, "rescore", "-config", bad); err == nil || !strings.Contains(err.Error(), "MinWord") {
+		t.Errorf("with an unknown option in -config: err %v, want it named", err)
+	}
+}
+
+func TestLocale(t *testing.T) {
+	setFlag(t, configFile, "")
+	setFlag(t, localeName, "")
+	got, err := runCapturingOpts(t, "generate", "-locale", "es")
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !strings.ContainsRune(got.Alphabet, 'ñ') || got.MinWordLen != 4 {
+		t.Errorf("with -locale es, Alphabet %q and MinWordLen %d, want ñ in the alphabet and 4", got.Alphabet, got.MinWordLen)
+	}
+
+	got, err = runCapturingOpts(t, "generate", "-min_word_len", "6", "-locale", "es")
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !strings.ContainsRune(got.Alphabet, 'ñ') || got.MinWordLen != 6 {
+		t.Errorf("with -locale es and -min_word_len 6, Alphabet %q and MinWordLen %d, want ñ in the alphabet and the flag's 6", got.Alphabet, got.MinWordLen)
+	}
+
+	if _, err := runCapturingOpts(t, "generate", "-locale", "xx"); err == nil || !strings.Contains(err.Error(), "en, es, fr") {
+		t.Errorf("with -locale xx: err %v, want the known locales listed", err)
+	}
+}
+
+func TestNYTPreset(t *testing.T) {
+	setFlag(t, configFile, "")
+	setFlag(t, localeName, "")
+	setFlag(t, nytPreset, false)
+	got, err := runCapturingOpts(t, "generate", "-nyt")
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	want := DefaultOptions()
+	want.MinWordLen, want.MaxPangrams, want.PangramBonus = 4, 1, 7
+	if got != want {
+		t.Errorf("with -nyt, options are %+v, want %+v", got, want)
+	}
+
+	got, err = runCapturingOpts(t, "generate", "-min_word_len", "5", "-nyt")
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got.MinWordLen != 5 || got.MaxPangrams != 1 {
+		t.Errorf("with -nyt and -min_word_len 5, MinWordLen %d and MaxPangrams %d, want the flag's 5 and 1", got.MinWordLen, got.MaxPangrams)
+	}
+}
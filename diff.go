@@ -0,0 +1,55 @@
+package main
+
+import "context"
+
+// changedMasks returns the letter masks of the words in exactly one of
+// oldWords and newWords: the words a dictionary change added or removed.
+func changedMasks(oldWords, newWords []string) []uint32 {
+	count := map[string]int{}
+	for _, w := range oldWords {
+		count[w] = 1
+	}
+	for _, w := range newWords {
+		count[w] |= 2
+	}
+	seen := map[uint32]bool{}
+	masks := []uint32{}
+	for w, c := range count {
+		if m := letterMask(w, opts.Alphabet); c != 3 && !seen[m] {
+			seen[m] = true
+			masks = append(masks, m)
+		}
+	}
+	return masks
+}
+
+// affected reports whether the answers for the letter set s, center letter
+// first, include a word with one of the changed masks. If anyCenter is set,
+// the center letter is ignored, such as when every center of s is tried.
+func affected(s string, changed []uint32, anyCenter bool) bool {
+	set := letterMask(s, opts.Alphabet)
+	center := letterMask(centerLetter(s), opts.Alphabet)
+	if anyCenter || !opts.RequireCenter {
+		center = 0
+	}
+	for _, m := range changed {
+		if m&set == m && m&center == center {
+			return true
+		}
+	}
+	return false
+}
+
+// onlyAffected sends each letter set from in to out if it is affected by the
+// changed masks, then closes out.
+func onlyAffected(ctx context.Context, in <-chan string, out chan<- string, changed []uint32) {
+	defer close(out)
+	for s := range in {
+		if !affected(s, changed, *bestCenter) {
+			continue
+		}
+		if !send(ctx, out, s) {
+			return
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestOldWordsFileRegeneratesAffectedPuzzles(t *testing.T) {
+	o := DefaultOptions()
+	o.Alphabet, o.NumLetters, o.MinWordLen, o.MinWords = "abcde", 3, 3, 1
+	setOpts(t, o)
+	setFlag(t, oldWordsFile, writeTestFile(t, "old.txt", "abc", "abd", "cde"))
+	setFlag(t, wordsFile, writeTestFile(t, "new.txt", "abc", "abd", "cde", "bcd"))
+	setFlag(t, parallel, 2)
+	got := []string{}
+	err := generate(context.Background(), nil, func(in <-chan puzzle) {
+		for p := range in {
+			got = append(got, p.letters)
+		}
+	})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	slices.Sort(got)
+	// Only the puzzles bcd is an answer to.
+	if want := []string{"bcd", "cbd", "dbc"}; !slices.Equal(got, want) {
+		t.Errorf("after adding bcd, generate made %q, want %q", got, want)
+	}
+}
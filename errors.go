@@ -0,0 +1,16 @@
+package main
+
+import "errors"
+
+// Errors callers can test for with errors.Is. A dictionary or frequency file
+// that doesn't exist is reported with an error wrapping fs.ErrNotExist.
+var (
+	// ErrInvalidOptions is wrapped by errors from Options.Validate.
+	ErrInvalidOptions = errors.New("invalid options")
+	// ErrEmptyDictionary is returned by LoadDictionary when every word is
+	// rejected.
+	ErrEmptyDictionary = errors.New("no valid words")
+	// ErrInvalidUTF8 is wrapped by the error LoadDictionary returns for a
+	// dictionary that isn't UTF-8.
+	ErrInvalidUTF8 = errors.New("invalid UTF-8")
+)
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestErrorKinds(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	setFlag(t, dictFormat, "auto")
+	setFlag(t, excludeWordsFile, "")
+	missing := filepath.Join(t.TempDir(), "missing.txt")
+	bad := DefaultOptions()
+	bad.MinWordLen = 0
+	for _, tc := range []struct {
+		name string
+		err  func() error
+		want error
+	}{
+		{"missing dictionary", func() error { _, _, err := readWords(missing); return err }, fs.ErrNotExist},
+		{"missing frequency file", func() error { return newWordIndex(nil).loadFreqs(missing) }, fs.ErrNotExist},
+		{"empty dictionary", func() error { _, _, err := readWords(writeTestFile(t, "dict.txt", "lap", "Zebra")); return err }, ErrEmptyDictionary},
+		{"empty JSON dictionary", func() error { _, _, err := readWords(writeTestFile(t, "dict.json", `["lap"]`)); return err }, ErrEmptyDictionary},
+		{"invalid options", func() error { return bad.Validate() }, ErrInvalidOptions},
+		{"invalid UTF-8", func() error {
+			_, _, err := LoadDictionary(strings.NewReader("caf\xe9s\n"), DefaultOptions())
+			return err
+		}, ErrInvalidUTF8},
+	} {
+		if err := tc.err(); !errors.Is(err, tc.want) {
+			t.Errorf("%s: err %v, want one wrapping %v", tc.name, err, tc.want)
+		}
+	}
+}
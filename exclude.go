@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// readExcludeWords reads the -exclude_words_file at path, one word per
+// line, normalized and folded as dictionary words are so they match.
+func readExcludeWords(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Open(%q): %w", path, err)
+	}
+	defer f.Close()
+	excluded := map[string]bool{}
+	err = eachLine(f, func(_ int, w string) error {
+		w = opts.normalize(w)
+		if opts.Lowercase {
+			w = foldToAlphabet(w, opts.Alphabet)
+		}
+		excluded[w] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return excluded, nil
+}
+
+// exclude removes the words in excluded from words, counting them in stats.
+// It is an error if no words are left.
+func (stats *LoadStats) exclude(words []string, excluded map[string]bool) ([]string, error) {
+	kept := words[:0]
+	for _, w := range words {
+		if excluded[w] {
+			stats.Excluded++
+			continue
+		}
+		kept = append(kept, w)
+	}
+	stats.Kept = len(kept)
+	if len(kept) == 0 {
+		return nil, ErrEmptyDictionary
+	}
+	return kept, nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/nickgraffis/spelling-bee/spellingbeetest"
+)
+
+func TestExcludeWordsFile(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	setFlag(t, dictFormat, "auto")
+	setFlag(t, freqFile, "")
+	setFlag(t, excludeWordsFile, writeTestFile(t, "exclude.txt", "plates", "zebra"))
+	idx, err := loadIndex(writeTestFile(t, "dict.txt", spellingbeetest.SampleDictionary()...))
+	if err != nil {
+		t.Fatalf("loadIndex: %v", err)
+	}
+	p, reason := checkPuzzle(idx, "aelprst")
+	if reason != "" {
+		t.Fatalf("checkPuzzle(aelprst) rejected it: %s", reason)
+	}
+	if slices.Contains(p.words, "plates") || len(p.words) != 11 {
+		t.Errorf("with plates excluded, the answers are %q, want the 11 others", p.words)
+	}
+	// The sample puzzle's 70 points, less 6 for plates.
+	if p.maxPts != 64 {
+		t.Errorf("with plates excluded, the puzzle is worth %d points, want 64", p.maxPts)
+	}
+}
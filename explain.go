@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// explain writes to w how the letter set s, center letter first, is judged:
+// its answers, pangrams, near pangrams if required, and points, and whether
+// it makes a puzzle, and if not, why.
+func explain(w io.Writer, idx *wordIndex, s string) error {
+	p, reason := checkPuzzle(idx, s)
+	if reason == "" {
+		reason = finalCheck(p)
+	}
+
+	check := func(ok bool) string {
+		if ok {
+			return "ok"
+		}
+		return "FAIL"
+	}
+	pangrams, common := []string{}, []string{}
+	pts := 0
+	for _, word := range p.words {
+		if isPangram(word, s) {
+			pangrams = append(pangrams, word)
+			if idx.common(word) {
+				common = append(common, word)
+			}
+		}
+		pts += ScoreWord(word, s)
+	}
+
+	fmt.Fprintf(w, "letters:  %s (center %s)\n", s, centerLetter(s))
+	fmt.Fprintf(w, "answers:  %d, need %d: %s\n", len(p.words), opts.MinWords, check(len(p.words) >= opts.MinWords))
+	if opts.MaxPangrams > 0 {
+		fmt.Fprintf(w, "pangrams: %d %v, need 1 to %d: %s\n", len(pangrams), pangrams, opts.MaxPangrams, check(len(pangrams) > 0 && len(pangrams) <= opts.MaxPangrams))
+	} else {
+		fmt.Fprintf(w, "pangrams: %d %v: %s\n", len(pangrams), pangrams, check(len(pangrams) > 0))
+	}
+	if opts.PangramFreq > 0 {
+		fmt.Fprintf(w, "common:   %d %v with frequency at least %v: %s\n", len(common), common, opts.PangramFreq, check(len(common) > 0))
+	}
+	if opts.RequireNearPangram {
+		near := nearPangrams(p.words, s)
+		fmt.Fprintf(w, "near:     %d %v: %s\n", len(near), near, check(len(near) > 0))
+	}
+	if opts.MinEasyWords > 0 {
+		easy := []string{}
+		for _, word := range p.words {
+			if idx.easy(word) {
+				easy = append(easy, word)
+			}
+		}
+		fmt.Fprintf(w, "easy:     %d %v, need %d: %s\n", len(easy), easy, opts.MinEasyWords, check(len(easy) >= opts.MinEasyWords))
+	}
+	maxPts := "no limit"
+	if opts.MaxPoints != 0 {
+		maxPts = fmt.Sprint(opts.MaxPoints)
+	}
+	fmt.Fprintf(w, "points:   %d, need %d to %s: %s\n", pts, opts.MinPoints, maxPts, check(inPointsRange(pts)))
+	if opts.MinAvgPoints > 0 && len(p.words) > 0 {
+		avg := float64(pts) / float64(len(p.words))
+		fmt.Fprintf(w, "average:  %.2f, need %v: %s\n", avg, opts.MinAvgPoints, check(avg >= opts.MinAvgPoints))
+	}
+	if opts.MinStartLetters > 0 {
+		n := startLetters(p.words)
+		fmt.Fprintf(w, "starts:   %d letters, need %d: %s\n", n, opts.MinStartLetters, check(n >= opts.MinStartLetters))
+	}
+	if reason != "" {
+		_, err := fmt.Fprintf(w, "REJECTED: %s\n", reason)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "ACCEPTED as %s: %s\n", p.letters, strings.Join(p.words, " "))
+	return err
+}
+
+// printRotations writes to w each letter set rotate makes from s, one per
+// line with its center letter, in the order generate tries them.
+func printRotations(w io.Writer, s string) error {
+	in, out := make(chan string, 1), make(chan string)
+	in <- s
+	close(in)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go rotate(ctx, in, out)
+	for r := range out {
+		if _, err := fmt.Fprintf(w, "%s (center %s)\n", r, centerLetter(r)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
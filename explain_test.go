@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	idx := sampleIndex(t)
+	for _, tc := range []struct {
+		letters string
+		want    []string
+	}{
+		{"raelpst", []string{"answers:  4, need 10: FAIL", "REJECTED: too few words"}},
+		{"aelprst", []string{"answers:  12, need 10: ok", "pangrams: 1 [plaster]: ok", "points:   70, need 0 to no limit: ok", "ACCEPTED as aelprst: apple pasta"}},
+	} {
+		var b strings.Builder
+		if err := explain(&b, idx, tc.letters); err != nil {
+			t.Fatalf("explain(%q): %v", tc.letters, err)
+		}
+		for _, w := range tc.want {
+			if !strings.Contains(b.String(), w) {
+				t.Errorf("explain(%q) wrote:\n%s\nwant a line with %q", tc.letters, b.String(), w)
+			}
+		}
+	}
+}
+
+func TestPrintRotations(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	var b strings.Builder
+	if err := printRotations(&b, "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "abc (center a)\nbca (center b)\ncab (center c)\n"; b.String() != want {
+		t.Errorf("printRotations(abc) wrote %q, want %q", b.String(), want)
+	}
+}
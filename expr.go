@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unicode"
+)
+
+// scoreVars are the variables a -score_expr expression can use.
+type scoreVars struct {
+	len              int // the word's length in letters
+	isPangram        int // 1 if the word uses every letter of the puzzle, else 0
+	isPerfectPangram int // 1 if it uses each letter exactly once, else 0
+	numLetters       int // the number of letters in the puzzle
+}
+
+// A scoreExpr is a compiled -score_expr expression.
+type scoreExpr func(v *scoreVars) int
+
+// parseScoreExpr compiles a C-like integer expression, such as
+// "len == 4 ? 1 : len + (isPangram ? 7 : 0)". It supports the variables of
+// scoreVars, true and false, integer literals, parentheses, and these
+// operators, loosest binding first:
+//
+//	?:  ||  &&  == != < <= > >=  + -  * / %  unary - !
+//
+// Comparisons and logical operators give 1 or 0, and any non-zero value is
+// true. Dividing by zero gives 0.
+func parseScoreExpr(src string) (scoreExpr, error) {
+	p := &exprParser{src: src}
+	p.next()
+	e, err := p.cond()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok != "" {
+		return nil, p.errorf("unexpected %q", p.tok)
+	}
+	return e, nil
+}
+
+// compiledScoreExpr caches the compiled Options.ScoreExpr.
+var compiledScoreExpr atomic.Pointer[struct {
+	src string
+	e   scoreExpr
+}]
+
+// scoreExprFor returns the compiled form of src, which Options.Validate has
+// already parsed successfully.
+func scoreExprFor(src string) scoreExpr {
+	if c := compiledScoreExpr.Load(); c != nil && c.src == src {
+		return c.e
+	}
+	e, err := parseScoreExpr(src)
+	if err != nil {
+		panic(fmt.Sprintf("unvalidated score expression %q: %v", src, err))
+	}
+	compiledScoreExpr.Store(&struct {
+		src string
+		e   scoreExpr
+	}{src, e})
+	return e
+}
+
+type exprParser struct {
+	src string
+	pos int    // offset of the next token after tok
+	tok string // the current token, or "" at the end
+	at  int    // offset of tok
+}
+
+func (p *exprParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("score expression %q at offset %d: %s", p.src, p.at, fmt.Sprintf(format, args...))
+}
+
+// next advances to the next token.
+func (p *exprParser) next() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+	p.at = p.pos
+	if p.pos == len(p.src) {
+		p.tok = ""
+		return
+	}
+	rest := p.src[p.pos:]
+	for _, op := range []string{"||", "&&", "==", "!=", "<=", ">="} {
+		if strings.HasPrefix(rest, op) {
+			p.tok = op
+			p.pos += len(op)
+			return
+		}
+	}
+	end := p.pos + 1
+	if c := rune(rest[0]); unicode.IsLetter(c) || unicode.IsDigit(c) {
+		for end < len(p.src) && (unicode.IsLetter(rune(p.src[end])) || unicode.IsDigit(rune(p.src[end]))) {
+			end++
+		}
+	}
+	p.tok = p.src[p.pos:end]
+	p.pos = end
+}
+
+// expect consumes tok or returns an error.
+func (p *exprParser) expect(tok string) error {
+	if p.tok != tok {
+		if p.tok == "" {
+			return p.errorf("want %q, got end of expression", tok)
+		}
+		return p.errorf("want %q, got %q", tok, p.tok)
+	}
+	p.next()
+	return nil
+}
+
+func (p *exprParser) cond() (scoreExpr, error) {
+	c, err := p.binary(0)
+	if err != nil || p.tok != "?" {
+		return c, err
+	}
+	p.next()
+	a, err := p.cond()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	b, err := p.cond()
+	if err != nil {
+		return nil, err
+	}
+	return func(v *scoreVars) int {
+		if c(v) != 0 {
+			return a(v)
+		}
+		return b(v)
+	}, nil
+}
+
+// binaryOps lists the binary operators by precedence, loosest first.
+var binaryOps = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func bool2int(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// binary parses operators of precedence level and tighter.
+func (p *exprParser) binary(level int) (scoreExpr, error) {
+	if level == len(binaryOps) {
+		return p.unary()
+	}
+	x, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, o := range binaryOps[level] {
+			if p.tok == o {
+				op = o
+			}
+		}
+		if op == "" {
+			return x, nil
+		}
+		p.next()
+		y, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = binaryOp(op, x, y)
+	}
+}
+
+func binaryOp(op string, x, y scoreExpr) scoreExpr {
+	switch op {
+	case "||":
+		return func(v *scoreVars) int { return bool2int(x(v) != 0 || y(v) != 0) }
+	case "&&":
+		return func(v *scoreVars) int { return bool2int(x(v) != 0 && y(v) != 0) }
+	case "==":
+		return func(v *scoreVars) int { return bool2int(x(v) == y(v)) }
+	case "!=":
+		return func(v *scoreVars) int { return bool2int(x(v) != y(v)) }
+	case "<":
+		return func(v *scoreVars) int { return bool2int(x(v) < y(v)) }
+	case "<=":
+		return func(v *scoreVars) int { return bool2int(x(v) <= y(v)) }
+	case ">":
+		return func(v *scoreVars) int { return bool2int(x(v) > y(v)) }
+	case ">=":
+		return func(v *scoreVars) int { return bool2int(x(v) >= y(v)) }
+	case "+":
+		return func(v *scoreVars) int { return x(v) + y(v) }
+	case "-":
+		return func(v *scoreVars) int { return x(v) - y(v) }
+	case "*":
+		return func(v *scoreVars) int { return x(v) * y(v) }
+	case "/":
+		return func(v *scoreVars) int {
+			if d := y(v); d != 0 {
+				return x(v) / d
+			}
+			return 0
+		}
+	}
+	return func(v *scoreVars) int {
+		if d := y(v); d != 0 {
+			return x(v) % d
+		}
+		return 0
+	}
+}
+
+func (p *exprParser) unary() (scoreExpr, error) {
+	switch p.tok {
+	case "-", "!":
+		op := p.tok
+		p.next()
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if op == "-" {
+			return func(v *scoreVars) int { return -x(v) }, nil
+		}
+		return func(v *scoreVars) int { return bool2int(x(v) == 0) }, nil
+	case "(":
+		p.next()
+		x, err := p.cond()
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (scoreExpr, error) {
+	tok := p.tok
+	if tok == "" {
+		return nil, p.errorf("unexpected end of expression")
+	}
+	if n, err := strconv.Atoi(tok); err == nil {
+		p.next()
+		return func(*scoreVars) int { return n }, nil
+	}
+	var x scoreExpr
+	switch tok {
+	case "len":
+		x = func(v *scoreVars) int { return v.len }
+	case "isPangram":
+		x = func(v *scoreVars) int { return v.isPangram }
+	case "isPerfectPangram":
+		x = func(v *scoreVars) int { return v.isPerfectPangram }
+	case "numLetters":
+		x = func(v *scoreVars) int { return v.numLetters }
+	case "true":
+		x = func(*scoreVars) int { return 1 }
+	case "false":
+		x = func(*scoreVars) int { return 0 }
+	default:
+		return nil, p.errorf("unknown name %q", tok)
+	}
+	p.next()
+	return x, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nickgraffis/spelling-bee/spellingbeetest"
+)
+
+func TestScoreExpr(t *testing.T) {
+	words := append(spellingbeetest.SampleDictionary(), "peal", "tsar", "plasters")
+	setOpts(t, DefaultOptions())
+	want := map[string]int{}
+	for _, w := range words {
+		want[w] = ScoreWord(w, "aelprst")
+	}
+
+	// The default rules, written as an expression, score every word the same.
+	o := DefaultOptions()
+	o.ScoreExpr = "len == 4 ? 1 : len + (isPangram ? 7 : 0)"
+	setOpts(t, o)
+	for _, w := range words {
+		if got := ScoreWord(w, "aelprst"); got != want[w] {
+			t.Errorf("with -score_expr %q, ScoreWord(%q) = %d, want %d", o.ScoreExpr, w, got, want[w])
+		}
+	}
+
+	// One point a word, and 3 more for a pangram that uses each letter once.
+	o.ScoreExpr = "1 + 3*isPerfectPangram"
+	setOpts(t, o)
+	for w, pts := range map[string]int{"peal": 1, "plates": 1, "plaster": 4, "plasters": 1} {
+		if got := ScoreWord(w, "aelprst"); got != pts {
+			t.Errorf("with -score_expr %q, ScoreWord(%q) = %d, want %d", o.ScoreExpr, w, got, pts)
+		}
+	}
+}
+
+func TestParseScoreExpr(t *testing.T) {
+	vars := &scoreVars{len: 6, isPangram: 1, numLetters: 7}
+	for src, want := range map[string]int{
+		"len":                      6,
+		"-len + 10":                4,
+		"len * 2 % 5":              2,
+		"len / 0":                  0,
+		"!isPangram || len > 5":    1,
+		"numLetters - len == 1":    1,
+		"(len < 5 ? 1 : 2) * 3":    6,
+		"false ? 1 : true ? 2 : 3": 2,
+	} {
+		e, err := parseScoreExpr(src)
+		if err != nil {
+			t.Errorf("parseScoreExpr(%q): %v", src, err)
+			continue
+		}
+		if got := e(vars); got != want {
+			t.Errorf("%q = %d, want %d", src, got, want)
+		}
+	}
+	for _, src := range []string{"", "len +", "(len", "len ? 1", "size", "len 4"} {
+		if _, err := parseScoreExpr(src); err == nil {
+			t.Errorf("parseScoreExpr(%q) succeeded, want an error", src)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// readFreqs reads a word frequency file: one word per line, followed by
+// whitespace and how common the word is, such as its count in a corpus.
+// Blank lines are skipped.
+func readFreqs(path string) (map[string]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Open(%q): %w", path, err)
+	}
+	defer f.Close()
+	freqs := map[string]float64{}
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: want a word and its frequency, got %q", path, line, sc.Text())
+		}
+		n, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: bad frequency %q for %q", path, line, fields[1], fields[0])
+		}
+		freqs[fields[0]] = n
+	}
+	return freqs, sc.Err()
+}
+
+// loadFreqs reads the frequency file at path into idx, for
+// Options.PangramFreq. An empty path loads nothing.
+func (idx *wordIndex) loadFreqs(path string) error {
+	if path == "" {
+		if opts.PangramFreq > 0 {
+			return fmt.Errorf("-pangram_freq needs a -freq_file")
+		}
+		if opts.MinEasyWords > 0 {
+			return fmt.Errorf("-min_easy_words needs a -freq_file")
+		}
+		return nil
+	}
+	freqs, err := readFreqs(path)
+	if err != nil {
+		return err
+	}
+	idx.setFreqs(freqs)
+	return nil
+}
+
+// setFreqs sets the frequency of each word in idx, words missing from freqs
+// having frequency 0.
+func (idx *wordIndex) setFreqs(freqs map[string]float64) {
+	idx.freqs = freqs
+
+	// Split the words into thirds for difficulty.
+	fs := make([]float64, 0, len(freqs))
+	for _, f := range freqs {
+		fs = append(fs, f)
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(fs)))
+	if len(fs) > 0 {
+		idx.commonFreq = fs[(len(fs)-1)/3]
+		idx.rareFreq = fs[(len(fs)-1)*2/3]
+	}
+}
+
+// difficulty returns how hard w is likely to be to find: "common" if it is
+// in the most frequent third of the frequency file's words, "uncommon" if it
+// is in the middle third, and "rare" otherwise, including if it is missing.
+func (idx *wordIndex) difficulty(w string) string {
+	switch f := idx.freqs[w]; {
+	case f >= idx.commonFreq:
+		return "common"
+	case f >= idx.rareFreq:
+		return "uncommon"
+	}
+	return "rare"
+}
+
+// common reports whether w is common enough to be a puzzle's pangram: its
+// frequency is at least Options.PangramFreq. Words missing from the
+// frequency file have frequency 0.
+func (idx *wordIndex) common(w string) bool {
+	return opts.PangramFreq <= 0 || idx.freqs[w] >= opts.PangramFreq
+}
+
+// easy reports whether w is an answer most players would find early: it is
+// common, by difficulty, and at most one letter longer than the shortest
+// answers allowed.
+func (idx *wordIndex) easy(w string) bool {
+	return utf8.RuneCountInString(w) <= opts.MinWordLen+1 && idx.difficulty(w) == "common"
+}
@@ -0,0 +1,85 @@
+package main
+
+import "testing"
+
+func TestPangramFreqRejectsRarePangram(t *testing.T) {
+	idx := sampleIndex(t)
+	if err := idx.loadFreqs(writeTestFile(t, "freq.txt", "plate 900", "apple 800", "plaster 3", "pasta 40")); err != nil {
+		t.Fatalf("loadFreqs: %v", err)
+	}
+	o := DefaultOptions()
+	o.PangramFreq = 10
+	setOpts(t, o)
+	if _, reason := checkPuzzle(idx, "aelprst"); reason != "no common pangram" {
+		t.Errorf("with plaster, the only pangram, used 3 times: checkPuzzle(aelprst) gave reason %q, want \"no common pangram\"", reason)
+	}
+	o.PangramFreq = 3
+	setOpts(t, o)
+	if _, reason := checkPuzzle(idx, "aelprst"); reason != "" {
+		t.Errorf("with -pangram_freq 3: checkPuzzle(aelprst) rejected it: %s", reason)
+	}
+}
+
+func TestDifficultyBuckets(t *testing.T) {
+	idx := sampleIndex(t)
+	err := idx.loadFreqs(writeTestFile(t, "freq.txt", "plate 600", "apple 500", "pasta 400", "tapas 300", "areal 200", "alert 100"))
+	if err != nil {
+		t.Fatalf("loadFreqs: %v", err)
+	}
+	want := map[string]string{
+		"plate": "common", "apple": "common",
+		"pasta": "uncommon", "tapas": "uncommon",
+		"areal": "rare", "alert": "rare", "plaster": "rare",
+	}
+	for w, d := range want {
+		if got := idx.difficulty(w); got != d {
+			t.Errorf("difficulty(%q) = %q, want %q", w, got, d)
+		}
+	}
+
+	ps := matchAll(idx, "aelprst")
+	if len(ps) != 1 {
+		t.Fatalf("made %d puzzles from aelprst, want 1", len(ps))
+	}
+	r := newPuzzleRecord(ps[0])
+	if len(r.Difficulty) != len(r.Words) {
+		t.Errorf("the JSON record has difficulties for %d of %d answers", len(r.Difficulty), len(r.Words))
+	}
+	for w, d := range want {
+		if r.Difficulty[w] != d {
+			t.Errorf("the JSON record gives %q difficulty %q, want %q", w, r.Difficulty[w], d)
+		}
+	}
+	if p, ok := bestCenterPuzzle(idx, "aelprst"); !ok || len(p.difficulty) != len(p.words) {
+		t.Errorf("with -best_center, the puzzle has difficulties for %d of %d answers", len(p.difficulty), len(p.words))
+	}
+}
+
+func TestMinEasyWords(t *testing.T) {
+	idx := sampleIndex(t)
+	// The top third, plate, apple and pasta, are common, and short enough
+	// to be easy; plaster is too long.
+	err := idx.loadFreqs(writeTestFile(t, "freq.txt", "plate 900", "apple 800", "pasta 700", "plaster 600",
+		"tapas 10", "areal 9", "alert 8", "pleat 7", "petal 6"))
+	if err != nil {
+		t.Fatalf("loadFreqs: %v", err)
+	}
+	o := DefaultOptions()
+	for n, want := range map[int]string{3: "", 4: "too few easy words"} {
+		o.MinEasyWords = n
+		setOpts(t, o)
+		if _, reason := checkPuzzle(idx, "aelprst"); reason != want {
+			t.Errorf("with three easy answers and -min_easy_words %d, checkPuzzle(aelprst) gave reason %q, want %q", n, reason, want)
+		}
+	}
+
+	// Only the long answers are common.
+	if err := idx.loadFreqs(writeTestFile(t, "freq.txt", "plaster 900", "plates 800", "plate 1")); err != nil {
+		t.Fatalf("loadFreqs: %v", err)
+	}
+	o.MinEasyWords = 1
+	setOpts(t, o)
+	if _, reason := checkPuzzle(idx, "aelprst"); reason != "too few easy words" {
+		t.Errorf("with no easy answers and -min_easy_words 1, checkPuzzle(aelprst) gave reason %q, want \"too few easy words\"", reason)
+	}
+}
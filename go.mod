@@ -0,0 +1,5 @@
+module github.com/nickgraffis/spelling-bee
+
+go 1.22
+
+require golang.org/x/text v0.21.0
@@ -0,0 +1,43 @@
+package main
+
+import "sort"
+
+// A wordIndex groups the dictionary's words by the set of letters each uses,
+// so the words playable with a set of letters can be found by visiting only
+// its subsets instead of scanning the whole dictionary.
+type wordIndex struct {
+	words  []string
+	byMask map[uint32][]int
+	freqs  map[string]float64 // from -freq_file, if set
+
+	// Words at least as frequent as commonFreq are common, and those less
+	// frequent than rareFreq are rare.
+	commonFreq, rareFreq float64
+}
+
+func newWordIndex(words []string) *wordIndex {
+	idx := &wordIndex{words: words, byMask: map[uint32][]int{}}
+	for i, w := range words {
+		m := letterMask(w, opts.Alphabet)
+		idx.byMask[m] = append(idx.byMask[m], i)
+	}
+	return idx
+}
+
+// match returns, in dictionary order, the words that use only letters in set
+// and use every letter in center.
+func (idx *wordIndex) match(set, center uint32) []string {
+	is := []int{}
+	// Visit every non-empty subset of set.
+	for sub := set; sub != 0; sub = (sub - 1) & set {
+		if sub&center == center {
+			is = append(is, idx.byMask[sub]...)
+		}
+	}
+	sort.Ints(is)
+	words := make([]string, len(is))
+	for j, i := range is {
+		words[j] = idx.words[i]
+	}
+	return words
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// linearMatch returns the words that use only the letters of s and its
+// first letter, by scanning every word, as matchWords did before wordIndex.
+func linearMatch(words []string, s string) []string {
+	matched := []string{}
+	for _, w := range words {
+		if containsOnly(w, s) && strings.Contains(w, s[:1]) {
+			matched = append(matched, w)
+		}
+	}
+	return matched
+}
+
+var matchSets = []string{"aelprst", "eacinrt", "odgnirt", "xbcdfgh"}
+
+func TestWordIndexMatchesLinearScan(t *testing.T) {
+	words := repoDictionary(t)
+	idx := newWordIndex(words)
+	for _, s := range matchSets {
+		got := idx.match(letterMask(s, opts.Alphabet), letterMask(s[:1], opts.Alphabet))
+		if want := linearMatch(words, s); !reflect.DeepEqual(got, want) {
+			t.Errorf("match(%q) = %d words, want the %d a linear scan finds", s, len(got), len(want))
+		}
+	}
+}
+
+func BenchmarkWordIndexMatch(b *testing.B) {
+	idx := newWordIndex(repoDictionary(b))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := matchSets[i%len(matchSets)]
+		idx.match(letterMask(s, opts.Alphabet), letterMask(s[:1], opts.Alphabet))
+	}
+}
+
+func BenchmarkLinearMatch(b *testing.B) {
+	words := repoDictionary(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		linearMatch(words, matchSets[i%len(matchSets)])
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// looksLikeJSON reports whether the dictionary being read by br starts with
+// a JSON array or object. A text dictionary's first word won't start with [
+// or {.
+func looksLikeJSON(br *bufio.Reader) bool {
+	b, _ := br.Peek(512)
+	b = bytes.TrimLeft(b, " \t\r\n")
+	return len(b) > 0 && (b[0] == '[' || b[0] == '{')
+}
+
+// LoadJSONDictionary is like LoadDictionary, but reads a JSON array of words
+// or, like dictionary.json, a JSON object whose keys are the words.
+func LoadJSONDictionary(r io.Reader, opts Options) (words []string, stats LoadStats, err error) {
+	dec := json.NewDecoder(r)
+	t, err := dec.Token()
+	if err != nil {
+		return nil, stats, fmt.Errorf("json: %v", err)
+	}
+	object := t == json.Delim('{')
+	if !object && t != json.Delim('[') {
+		return nil, stats, fmt.Errorf("json: want an array of words or an object keyed by word, got %v", t)
+	}
+	words = []string{}
+	for dec.More() {
+		var w string
+		if object {
+			t, err := dec.Token()
+			if err != nil {
+				return nil, stats, fmt.Errorf("json: %v", err)
+			}
+			w = t.(string)
+			// Skip the value, such as a definition.
+			var v json.RawMessage
+			err = dec.Decode(&v)
+		} else {
+			err = dec.Decode(&w)
+		}
+		if err != nil {
+			return nil, stats, fmt.Errorf("json: word %d: %v", stats.Read+1, err)
+		}
+		if w, ok := stats.filter(w, opts); ok {
+			words = append(words, w)
+		}
+	}
+	return stats.kept(words)
+}
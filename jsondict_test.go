@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/nickgraffis/spelling-bee/spellingbeetest"
+)
+
+func TestJSONDictionaryMatchesText(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	setFlag(t, excludeWordsFile, "")
+	dict := append(spellingbeetest.SampleDictionary(), "Zebra", "plate")
+	setFlag(t, dictFormat, "text")
+	want, wantStats, err := readWords(writeTestFile(t, "dict.txt", dict...))
+	if err != nil {
+		t.Fatalf("reading the text dictionary: %v", err)
+	}
+
+	array, err := json.Marshal(dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	object := map[string]string{}
+	for _, w := range dict {
+		object[w] = "a definition"
+	}
+	objectJSON, err := json.Marshal(object)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name, format, content string
+	}{
+		{"array", "json", string(array)},
+		{"array", "auto", string(array)},
+		{"object", "auto", string(objectJSON)},
+	} {
+		setFlag(t, dictFormat, tc.format)
+		got, stats, err := readWords(writeTestFile(t, "dict.json", tc.content))
+		if err != nil {
+			t.Errorf("reading a JSON %s with -dict_format %s: %v", tc.name, tc.format, err)
+			continue
+		}
+		// The object has each word once, with its keys sorted by json.Marshal.
+		if tc.name == "object" {
+			slices.Sort(got)
+			sorted := slices.Clone(want)
+			slices.Sort(sorted)
+			sorted = slices.Compact(sorted)
+			if !slices.Equal(got, sorted) {
+				t.Errorf("a JSON object with -dict_format %s gave %q, want %q", tc.format, got, sorted)
+			}
+			continue
+		}
+		if !slices.Equal(got, want) || stats != wantStats {
+			t.Errorf("a JSON array with -dict_format %s gave %q, %+v; want %q, %+v as from text", tc.format, got, stats, want, wantStats)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// localeName and nytPreset are set by -locale and -nyt, registered by
+// addPuzzleFlags.
+var (
+	localeName = new(string)
+	nytPreset  = new(bool)
+)
+
+// A locale is a preset of the options that depend on a dictionary's
+// language. Letters that are rare outside loanwords are left out of the
+// alphabet.
+type locale struct {
+	alphabet   string
+	minWordLen int
+}
+
+var locales = map[string]locale{
+	"en": {alphabet: defaultAlphabet, minWordLen: 5},
+	// Without k and w.
+	"es": {alphabet: "abcdefghijlmnñopqrstuvxyz", minWordLen: 4},
+	// Without k and w. Accented letters are folded to plain ones by the
+	// dictionaries this is used with.
+	"fr": {alphabet: "abcdefghijlmnopqrstuvxyz", minWordLen: 4},
+}
+
+// applyLocale sets opts from the named locale. Flags set explicitly in fs
+// are applied again afterwards, so they override it.
+func applyLocale(name string, fs *flag.FlagSet) error {
+	l, ok := locales[name]
+	if !ok {
+		names := []string{}
+		for n := range locales {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown locale %q, want one of %s", name, strings.Join(names, ", "))
+	}
+	return keepingFlags(fs, func() error {
+		opts.Alphabet = l.alphabet
+		opts.MinWordLen = l.minWordLen
+		return nil
+	})
+}
+
+// applyNYT sets opts to match the New York Times game: seven letters with
+// one center, answers of four letters or more, at most one pangram, and its
+// scoring, a point for a four-letter answer, a point a letter for longer
+// ones and 7 more for a pangram. The alphabet is left alone, though the
+// Times never uses S. Flags set explicitly in fs are applied again
+// afterwards, so they override it.
+func applyNYT(fs *flag.FlagSet) error {
+	return keepingFlags(fs, func() error {
+		opts.NumLetters = 7
+		opts.Centers = 1
+		opts.RequireCenter = true
+		opts.MinWordLen = 4
+		opts.MaxPangrams = 1
+		opts.FourLetterScore = 1
+		opts.PangramBonus = 7
+		opts.PangramBonusMode = "fixed"
+		opts.PerfectPangramBonus = 0
+		opts.ScoreExpr = ""
+		return nil
+	})
+}
@@ -2,47 +2,568 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"math/big"
+	"math/bits"
+	"math/rand"
 	"os"
+	"os/signal"
 	"runtime/pprof"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
-const alphabet = "abcdefghijklmnopqrstuvwxyz"
+// opts holds the puzzle options, bound to flags by addPuzzleFlags and
+// addScoreFlags.
+var opts = DefaultOptions()
 
+// Flags shared by every subcommand that builds puzzles from the dictionary.
 var (
-	wordsFile  = flag.String("words_file", "./dict.txt", "File containing valid words")
-	numLetters = flag.Int("num_letters", 7, "Number of letters in resulting puzzles")
-	parallel   = flag.Int("parallel", 100, "Number of goroutines to use to generate puzzles")
-	v          = flag.Bool("v", true, "verbose logging")
+	wordsFile  = new(string)
+	dictFormat = new(string)
+	freqFile   = new(string)
+	v          = new(bool)
 
-	cpuprofile = flag.String("cpuprofile", "", "write cpu profile to file")
+	excludeWordsFile  = new(string)
+	verboseRejections = new(bool)
 )
 
+func addPuzzleFlags(fs *flag.FlagSet) {
+	fs.StringVar(wordsFile, "words_file", "./dict.txt", "File containing valid words")
+	fs.StringVar(dictFormat, "dict_format", "auto", "Format of -words_file: text, one word per line; json, an array of words or an object keyed by word; tsv, a word and an integer weight per line, used like -freq_file; or auto to tell from its start")
+	fs.BoolVar(v, "v", true, "verbose logging")
+	fs.BoolVar(verboseRejections, "verbose_rejections", false, "Log each rejected letter set with its answer and pangram counts; implies -debug")
+	fs.BoolVar(nytPreset, "nyt", false, "Match the New York Times game: 7 letters, answers of 4 or more letters, at most one pangram and its scoring; other flags and -config override it")
+	fs.StringVar(localeName, "locale", "", "Language preset for -alphabet and -min_word_len: en, es or fr; other flags and -config override it")
+	fs.StringVar(&opts.Alphabet, "alphabet", opts.Alphabet, "Letters puzzles are made from")
+	fs.BoolVar(&opts.Lowercase, "lowercase", opts.Lowercase, "Fold dictionary words to the alphabet's case instead of skipping words with capitals")
+	fs.StringVar(&opts.Normalize, "normalize", opts.Normalize, "Unicode normalization form, NFC or NFD, to put -alphabet and dictionary words in, so composed and decomposed accented letters match (empty means none)")
+	fs.IntVar(&opts.MinWordLen, "min_word_len", opts.MinWordLen, "Length of the shortest answer")
+	fs.IntVar(&opts.MaxWordLen, "max_word_len", opts.MaxWordLen, "Length of the longest answer (0 means no limit)")
+	fs.IntVar(&opts.MinDistinctLetters, "min_distinct_in_word", opts.MinDistinctLetters, "Fewest different letters an answer may use, to rule out answers like \"aaaa\" (0 means no limit)")
+	fs.IntVar(&opts.MinWords, "min_words", opts.MinWords, "Fewest answers a puzzle may have")
+	fs.BoolVar(&opts.RequireCenter, "require_center", opts.RequireCenter, "Answers must use the center letter")
+	addCentersFlag(fs)
+	fs.StringVar(excludeWordsFile, "exclude_words_file", "", "File of words, one per line, that are never answers, though they are in -words_file")
+	fs.StringVar(freqFile, "freq_file", "", "File of words and how common each is, one \"word frequency\" pair per line")
+	fs.IntVar(&opts.MaxPangrams, "max_pangrams", opts.MaxPangrams, "Reject puzzles with more than this many pangrams (0 means no limit)")
+	fs.BoolVar(&opts.RequireNearPangram, "require_near_pangram", opts.RequireNearPangram, "Reject puzzles without an answer that uses all but one of their letters")
+	fs.IntVar(&opts.MinEasyWords, "min_easy_words", opts.MinEasyWords, "Reject puzzles with fewer than this many easy answers: those in the most frequent third of -freq_file and at most -min_word_len+1 letters long (0 means no limit)")
+	fs.Float64Var(&opts.PangramFreq, "pangram_freq", opts.PangramFreq, "Reject puzzles without a pangram at least this common in -freq_file (0 means any pangram)")
+	addScoreFlags(fs)
+}
+
+// addCentersFlag registers -centers, which decides how letter sets are read.
+func addCentersFlag(fs *flag.FlagSet) {
+	fs.IntVar(&opts.Centers, "centers", opts.Centers, "Number of center letters, at the start of each letter set, that every answer must use")
+}
+
+// addScoreFlags registers only the flags ScoreWord depends on.
+func addScoreFlags(fs *flag.FlagSet) {
+	fs.IntVar(&opts.NumLetters, "num_letters", opts.NumLetters, "Number of letters in resulting puzzles")
+	fs.IntVar(&opts.FourLetterScore, "four_letter_score", opts.FourLetterScore, "Points earned by a four-letter word")
+	fs.IntVar(&opts.PangramBonus, "pangram_bonus", opts.PangramBonus, "Bonus points for a pangram in fixed mode (-1 means num_letters)")
+	fs.StringVar(&opts.PangramBonusMode, "pangram_bonus_mode", opts.PangramBonusMode, "How pangrams are rewarded: fixed (-pangram_bonus points) or length (the word's length again)")
+	fs.StringVar(&opts.ScoreExpr, "score_expr", opts.ScoreExpr, "Expression giving a word's points from len, isPangram, isPerfectPangram and numLetters, replacing the other scoring flags, e.g. \"len == 4 ? 1 : len + (isPangram ? 7 : 0)\"")
+	fs.IntVar(&opts.PerfectPangramBonus, "perfect_pangram_bonus", opts.PerfectPangramBonus, "Extra points for a perfect pangram, which uses each letter exactly once")
+}
+
+// outDir is registered on the flag sets of subcommands that read or write
+// puzzle files.
+var outDir = new(string)
+
+func addOutDirFlag(fs *flag.FlagSet) {
+	fs.StringVar(outDir, "out_dir", "./puzzels", "Directory puzzle files are written to")
+}
+
+// quiet and debug are registered on every subcommand's flag set.
+var (
+	quiet = new(bool)
+	debug = new(bool)
+)
+
+var generateFlags = flag.NewFlagSet("generate", flag.ContinueOnError)
+
+var (
+	parallel    = generateFlags.Int("parallel", 100, "Number of goroutines to use to generate puzzles")
+	genParallel = generateFlags.Int("gen_parallel", 0, "Most goroutines to use to generate letter sets (0 means no limit)")
+	maxMemory   = generateFlags.Int("max_memory", 0, "Soft limit on heap size in MiB; generation pauses while it's exceeded (0 means no limit)")
+	timeout     = generateFlags.Duration("timeout", 0, "Stop generating after this long, keeping the puzzles already written (0 means no limit)")
+	noRotate    = generateFlags.Bool("no_rotate", false, "Generate one puzzle per letter set instead of one per choice of center (implied by -require_center=false)")
+
+	shuffleOrder = generateFlags.Bool("shuffle_order", false, "Generate letter sets in a random order instead of alphabetically; every set is still generated")
+	seed         = generateFlags.Int64("seed", 0, "Seed for -shuffle_order, to repeat an earlier order (0 means pick one, which is logged)")
+
+	bestCenter   = generateFlags.Bool("best_center", false, "Generate one puzzle per letter set, using the center that gives the best puzzle")
+	bestCenterBy = generateFlags.String("best_center_by", "words", "What makes the best center for -best_center: words or points")
+
+	letters     = generateFlags.String("letters", "", "Generate only the puzzle for this letter set, center letter first")
+	lettersFile = generateFlags.String("letters_file", "", "File containing letter sets to generate, one per line, center letter first")
+
+	format              = generateFlags.String("format", "txt", "Output formats, comma-separated: txt (a file per puzzle in -out_dir), png (a hive image per puzzle in -out_dir), json (NDJSON), csv, yaml, flat (a line per puzzle: letters, center, answers and points) or hints (answer counts by first letter and length, without the answers)")
+	output              = generateFlags.String("output", "", "File to write json, csv, yaml, flat or hints output to (default OUT_DIR/puzzles.FORMAT), or archive:FILE to write txt or png puzzles to a zip archive instead of -out_dir")
+	gzipOutput          = generateFlags.Bool("gzip_output", false, "Gzip json, csv, yaml, flat or hints output")
+	maxOpenFiles        = generateFlags.Int("max_open_files", 64, "Most txt puzzle files to have open at once")
+	shardOutputByCenter = generateFlags.Bool("shard_output_by_center", false, "Write txt puzzles to a subdirectory of -out_dir per center letter")
+	txtHeader           = generateFlags.Bool("txt_header", false, "Start each txt puzzle with \"center: X\" and \"letters: XYZ\" lines")
+	scoreFirst          = generateFlags.Bool("score_first", false, "Write the points on the first line of txt puzzles instead of the last")
+	markPangrams        = generateFlags.Bool("mark_pangrams", false, "Follow each pangram in txt puzzles with a space and -pangram_mark")
+	pangramMark         = generateFlags.String("pangram_mark", "*", "Mark written after pangrams with -mark_pangrams")
+	groupAnagrams       = generateFlags.Bool("group_anagrams", false, "Write answers that are anagrams of each other next to each other")
+	outputCase          = generateFlags.String("output_case", "lower", "Case of the letters and answers written: lower or upper")
+
+	manifestFile        = generateFlags.String("manifest", "", "If set, write a JSON manifest of the written puzzles to this file")
+	manifestOnly        = generateFlags.Bool("manifest_only", false, "Instead of writing puzzles, rebuild -manifest from the txt puzzles already in -out_dir")
+	sortManifestBy      = generateFlags.String("sort_manifest_by", "words", "Order of manifest entries, highest first: words or points")
+	explainLetters      = generateFlags.String("explain", "", "Instead of writing puzzles, explain why this letter set, center letter first, does or doesn't make a puzzle")
+	showRotations       = generateFlags.String("show_rotations", "", "Instead of writing puzzles, print each rotation generate tries for this letter set and its center")
+	minDictionary       = generateFlags.Bool("min_dictionary", false, "Instead of writing puzzles, write the answers of the puzzles in -letters and -letters_file, once each, to -output or stdout")
+	lettersOnly         = generateFlags.Bool("letters_only", false, "Instead of writing puzzles, write each letter set to -output or stdout, without loading the dictionary")
+	validateOnly        = generateFlags.Bool("validate_only", false, "Instead of writing puzzles, report how many dictionary words each filter rejects")
+	reportUnusedLetters = generateFlags.Bool("report_unused_letters", false, "Instead of writing puzzles, report how often each letter is the center or an outer letter of one")
+
+	targetPuzzles = generateFlags.Int("target_puzzles", 0, "Choose -min_words, overriding it, so that about this many puzzles are written, by first sampling the letter sets (0 means use -min_words)")
+	sampleEvery   = generateFlags.Int("sample_every", 100, "With -target_puzzles, sample every Nth letter set")
+
+	chainLen = generateFlags.Int("chain", 0, "Instead of every puzzle, write a chain of this many, each of whose letter sets differs from the one before in one letter (0 means no chain)")
+
+	firstN  = generateFlags.Int("first_n", 0, "Stop after this many puzzles have been written (0 means no limit)")
+	topK    = generateFlags.Int("top_per_center", 0, "Write only this many puzzles for each center letter, those with the most points (0 means write every puzzle)")
+	balance = generateFlags.Int("balance_centers", 0, "Write only this many puzzles, spread as evenly as possible over the center letters (0 means write every puzzle)")
+
+	oldWordsFile = generateFlags.String("old_words_file", "", "Earlier version of -words_file; only puzzles whose answers changed since it are generated. Puzzles that no longer qualify are not removed")
+
+	resume = generateFlags.String("resume", "", "Manifest from an earlier run; puzzles it lists are not generated again, and are kept in the new -manifest")
+
+	cpuprofile = generateFlags.String("cpuprofile", "", "write cpu profile to file")
+)
+
+// A command is a subcommand of the tool, with its own flags.
+type command struct {
+	name    string
+	summary string
+	flags   *flag.FlagSet
+	run     func(args []string) error
+}
+
+var commands []*command
+
+func init() {
+	addPuzzleFlags(generateFlags)
+	addPuzzleFlags(serveFlags)
+	addScoreFlags(rescoreFlags)
+	addScoreFlags(verifyFlags)
+	addOutDirFlag(generateFlags)
+	generateFlags.IntVar(&opts.MinVowels, "min_vowels", opts.MinVowels, "Only generate letter sets with at least this many vowels (a, e, i, o and u); sets from -letters and -letters_file are kept")
+	generateFlags.IntVar(&opts.MaxVowels, "max_vowels", opts.MaxVowels, "Only generate letter sets with at most this many vowels (0 means no limit)")
+	generateFlags.BoolVar(&opts.VowelY, "vowel_y", opts.VowelY, "Count y as a vowel for -min_vowels and -max_vowels")
+	generateFlags.IntVar(&opts.MinPoints, "min_points", opts.MinPoints, "Only write puzzles worth at least this many points")
+	generateFlags.Float64Var(&opts.MinAvgPoints, "min_avg_points", opts.MinAvgPoints, "Only write puzzles whose answers average at least this many points (0 means no limit)")
+	generateFlags.IntVar(&opts.MinStartLetters, "min_start_letters", opts.MinStartLetters, "Only write puzzles whose answers start with at least this many different letters (0 means no limit)")
+	generateFlags.IntVar(&opts.MaxPoints, "max_points", opts.MaxPoints, "Only write puzzles worth at most this many points (0 means no limit)")
+	addOutDirFlag(cleanFlags)
+	addOutDirFlag(verifyFlags)
+	addCentersFlag(verifyFlags)
+
+	commands = []*command{
+		{"generate", "write every puzzle to -out_dir (the default)", generateFlags, runGenerate},
+		{"serve", "serve puzzles over HTTP", serveFlags, runServe},
+		{"clean", "remove generated puzzle files", cleanFlags, runClean},
+		{"render", "draw a puzzle's letters as a PNG", renderFlags, runRender},
+		{"rescore", "re-score NDJSON puzzles read from stdin", rescoreFlags, runRescore},
+		{"verify", "check the puzzle files in -out_dir", verifyFlags, runVerify},
+		{"version", "print the version and build information", versionFlags, runVersion},
+	}
+	for _, c := range commands {
+		c.flags.BoolVar(quiet, "quiet", false, "Only print errors; overrides -v")
+		c.flags.BoolVar(debug, "debug", false, "Also print debug messages, such as why letter sets are rejected")
+		c.flags.StringVar(configFile, "config", "", "JSON file of puzzle options, keyed by Options field name; flags override it")
+	}
+}
+
+// main exits with status 0 on success, 2 if the run completed but some
+// puzzles could not be written, and 1 on any other error.
 func main() {
+	err := run(os.Args[1:])
+	var partial *partialError
+	if errors.As(err, &partial) {
+		log.Print(err)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run parses args for the subcommand they name and runs it. Arguments that
+// don't start with a subcommand name are flags for generate.
+func run(args []string) error {
+	name := "generate"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	var cmd *command
+	for _, c := range commands {
+		if c.name == name {
+			cmd = c
+		}
+	}
+	if cmd == nil {
+		printUsage(os.Stderr)
+		return fmt.Errorf("unknown command %q", name)
+	}
+	if err := cmd.flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+	if *localeName != "" {
+		if err := applyLocale(*localeName, cmd.flags); err != nil {
+			return err
+		}
+	}
+	if *nytPreset {
+		if err := applyNYT(cmd.flags); err != nil {
+			return err
+		}
+	}
+	if *configFile != "" {
+		if err := loadConfig(*configFile, cmd.flags); err != nil {
+			return err
+		}
+	}
+	// Normalize before validating, so letters that only differ in form
+	// are reported as repeated.
+	if validateNormalize(opts.Normalize) == nil {
+		opts.Alphabet = opts.normalize(opts.Alphabet)
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	if *debug || *verboseRejections {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+	}
+	if *quiet {
+		// Informational messages go through slog; errors are logged with
+		// log.Fatal and are still printed.
+		*v = false
+		slog.SetLogLoggerLevel(slog.LevelError)
+	}
+	return cmd.run(cmd.flags.Args())
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: spellingbee <command> [flags]")
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.summary)
+	}
+}
+
+// runGenerate generates every puzzle and writes it in -format.
+func runGenerate(args []string) error {
 	start := time.Now()
-	flag.Parse()
 
 	if *cpuprofile != "" {
 		f, err := os.Create(*cpuprofile)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 		pprof.StartCPUProfile(f)
 		defer pprof.StopCPUProfile()
 	}
 
-	strings := make(chan string)
-	go genAllStrings(*numLetters, strings)
+	if err := sortManifest(nil, *sortManifestBy); err != nil {
+		return err
+	}
+	if *balance < 0 {
+		return fmt.Errorf("-balance_centers is %d, want at least 0", *balance)
+	}
+	if *topK < 0 {
+		return fmt.Errorf("-top_per_center is %d, want at least 0", *topK)
+	}
+	if *chainLen < 0 {
+		return fmt.Errorf("-chain is %d, want at least 0", *chainLen)
+	}
+	if *firstN < 0 {
+		return fmt.Errorf("-first_n is %d, want at least 0", *firstN)
+	}
+	if *genParallel < 0 {
+		return fmt.Errorf("-gen_parallel is %d, want at least 0", *genParallel)
+	}
+	if *genParallel > 0 {
+		genSem = make(chan struct{}, *genParallel)
+	}
+	if *targetPuzzles < 0 {
+		return fmt.Errorf("-target_puzzles is %d, want at least 0", *targetPuzzles)
+	}
+	if *sampleEvery < 1 {
+		return fmt.Errorf("-sample_every is %d, want at least 1", *sampleEvery)
+	}
+	if *bestCenterBy != "words" && *bestCenterBy != "points" {
+		return fmt.Errorf("unknown -best_center_by %q, want words or points", *bestCenterBy)
+	}
+	if *validateOnly {
+		// Report the counts even if every word was rejected.
+		_, stats, err := readWords(*wordsFile)
+		if err != nil && !errors.Is(err, ErrEmptyDictionary) {
+			return err
+		}
+		if err := stats.report(os.Stdout); err != nil {
+			return err
+		}
+		return err
+	}
+	if *explainLetters != "" {
+		if err := validateLetters(*explainLetters, opts.NumLetters); err != nil {
+			return err
+		}
+		idx, err := loadIndex(*wordsFile)
+		if err != nil {
+			return err
+		}
+		return explain(os.Stdout, idx, *explainLetters)
+	}
+	if *manifestOnly {
+		if *manifestFile == "" {
+			return fmt.Errorf("-manifest_only needs a -manifest")
+		}
+		entries, err := rebuildManifest(*outDir)
+		if err != nil {
+			return err
+		}
+		if err := writeManifest(*manifestFile, entries, *sortManifestBy); err != nil {
+			return fmt.Errorf("writeManifest(%q): %v", *manifestFile, err)
+		}
+		slog.Info("Rebuilt manifest", "puzzles", len(entries))
+		return nil
+	}
+	if *minDictionary {
+		return writeMinDictionary()
+	}
+	if *showRotations != "" {
+		if err := validateLetters(*showRotations, opts.NumLetters); err != nil {
+			return err
+		}
+		return printRotations(os.Stdout, *showRotations)
+	}
+	if !opts.RequireCenter || *bestCenter {
+		// Without a required letter, every center makes the same puzzle;
+		// with -best_center, matchWords tries every center itself.
+		*noRotate = true
+	}
+
+	var resumed []manifestEntry
+	done := map[string]bool{}
+	if *resume != "" {
+		var err error
+		if resumed, err = readManifest(*resume); err != nil {
+			return fmt.Errorf("readManifest(%q): %v", *resume, err)
+		}
+		for _, e := range resumed {
+			done[resumeKey(e.Letters)] = true
+		}
+	}
+
+	// Stop cleanly on interrupt, so the manifest is still written and the run
+	// can be resumed.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	if *lettersOnly {
+		return writeLetterSets(ctx)
+	}
+	if *reportUnusedLetters {
+		var t letterTally
+		if err := generate(ctx, done, func(in <-chan puzzle) { t = tallyLetters(in) }); err != nil {
+			return err
+		}
+		return t.report(os.Stdout)
+	}
+
+	pw, err := newPuzzleWriter()
+	if err != nil {
+		return err
+	}
+	var written []manifestEntry
+	var writeErr error
+	consume := func(in <-chan puzzle) {
+		if *topK > 0 {
+			in = topPerCenter(in, *topK)
+		}
+		if *balance > 0 {
+			in = balanceCenters(in, *balance)
+		}
+		if *firstN > 0 {
+			in = firstPuzzles(in, *firstN, func() { cancel(errFirstN) })
+		}
+		written, writeErr = writePuzzles(ctx, in, pw)
+	}
+	if *chainLen > 0 {
+		err = chain(ctx, *chainLen, consume)
+	} else {
+		err = generate(ctx, done, consume)
+	}
+	if err != nil {
+		return err
+	}
+	var partial *partialError
+	if writeErr != nil && !errors.As(writeErr, &partial) {
+		return writeErr
+	}
+	if ctx.Err() != nil {
+		slog.Info("Stopped early", "timeout", *timeout, "err", context.Cause(ctx))
+	}
+	logRejections()
+	slog.Info("Wrote puzzles", "count", len(written))
+	written = append(resumed, written...)
+	if *manifestFile != "" {
+		if err := writeManifest(*manifestFile, written, *sortManifestBy); err != nil {
+			return fmt.Errorf("writeManifest(%q): %v", *manifestFile, err)
+		}
+	}
+	elapsed := time.Since(start)
+	slog.Info("Binomial took", "seconds", elapsed.Nanoseconds()/1000000000)
+	return writeErr
+}
 
+// letterSets returns a channel of the letter sets given with -letters or
+// -letters_file, or else every possible letter set, once per choice of
+// center unless -no_rotate is set.
+func letterSets(ctx context.Context) (chan string, error) {
 	rotated := make(chan string)
-	go rotate(strings, rotated)
+	if *letters != "" || *lettersFile != "" {
+		sets, err := readLetterSets()
+		if err != nil {
+			return nil, err
+		}
+		go emitStrings(ctx, sets, rotated)
+		return rotated, nil
+	}
+	sets := make(chan string)
+	go genAllStrings(ctx, opts.NumLetters, sets)
+	if opts.MinVowels > 0 || opts.MaxVowels > 0 {
+		// Before rotating, as every rotation has the same vowels.
+		balanced := make(chan string)
+		go vowelLetters(ctx, sets, balanced)
+		sets = balanced
+	}
+	if *shuffleOrder {
+		shuffled := make(chan string)
+		go shuffleStrings(ctx, *seed, sets, shuffled)
+		sets = shuffled
+	}
+	if *noRotate {
+		return sets, nil
+	}
+	go rotate(ctx, sets, rotated)
+	return rotated, nil
+}
+
+// writeLetterSets writes every letter set from letterSets to -output, or
+// stdout, one per line, without loading the dictionary.
+func writeLetterSets(ctx context.Context) error {
+	sets, err := letterSets(ctx)
+	if err != nil {
+		return err
+	}
+	var f *os.File
+	b := bufio.NewWriter(os.Stdout)
+	if *output != "" {
+		if f, err = os.Create(*output); err != nil {
+			return err
+		}
+		defer f.Close()
+		b = bufio.NewWriter(f)
+	}
+	n := 0
+	for s := range sets {
+		fmt.Fprintln(b, s)
+		n++
+	}
+	if err := b.Flush(); err != nil {
+		return err
+	}
+	slog.Info("Wrote letter sets", "count", n)
+	if f != nil {
+		return f.Close()
+	}
+	return nil
+}
+
+// generate builds the puzzle for every letter set from letterSets, skipping
+// those whose resumeKey is in done. With -old_words_file, only
+// letter sets whose answers differ between the two dictionaries are built.
+// It passes the puzzles to consume, which must read them until the channel
+// is closed, and returns when consume does.
+func generate(ctx context.Context, done map[string]bool, consume func(<-chan puzzle)) error {
+	idx, err := loadIndex(*wordsFile)
+	if err != nil {
+		return err
+	}
+	var changed []uint32
+	if *oldWordsFile != "" {
+		oldWords, _, err := readWords(*oldWordsFile)
+		if err != nil && !errors.Is(err, ErrEmptyDictionary) {
+			return err
+		}
+		changed = changedMasks(oldWords, idx.words)
+		slog.Info("Dictionary changes", "letter_masks", len(changed))
+	}
+	if *targetPuzzles > 0 {
+		counts, err := sampleAnswerCounts(ctx, idx, *sampleEvery)
+		if err != nil {
+			return err
+		}
+		opts.MinWords = targetMinWords(counts, *sampleEvery, *targetPuzzles)
+		slog.Info("Chose min words", "min_words", opts.MinWords, "sampled_puzzles", len(counts), "target", *targetPuzzles)
+	}
+
+	rotated, err := letterSets(ctx)
+	if err != nil {
+		return err
+	}
+	if len(done) > 0 {
+		unwritten := make(chan string)
+		go skipLetters(ctx, rotated, unwritten, done)
+		rotated = unwritten
+	}
+
+	if changed != nil {
+		affectedSets := make(chan string)
+		go onlyAffected(ctx, rotated, affectedSets, changed)
+		rotated = affectedSets
+	}
+	if *maxMemory > 0 {
+		g := newMemGuard(uint64(*maxMemory) << 20)
+		watchCtx, stopWatching := context.WithCancel(ctx)
+		defer stopWatching()
+		go g.watch(watchCtx, 100*time.Millisecond)
+		throttled := make(chan string)
+		go throttle(ctx, g, rotated, throttled)
+		rotated = throttled
+	}
 
 	puzzles := make(chan puzzle)
 
@@ -51,33 +572,30 @@ func main() {
 	wg2.Add(1)
 	go func() {
 		defer wg2.Done()
-		writePuzzles(puzzles)
+		consume(puzzles)
 	}()
 
-	allWords := genAllWords()
-
 	// Consume rotated words and generate puzzles.
 	var wg sync.WaitGroup
 	for i := 0; i < *parallel; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			matchWords(allWords, rotated, puzzles)
+			matchWords(ctx, idx, rotated, puzzles)
 		}()
 	}
 	wg.Wait()
 	// When puzzle generators are done, close puzzles. This will cause
-	// writePuzzles to finish, and the program to exit.
+	// consume to finish, and the program to exit.
 	close(puzzles)
 
 	wg2.Wait()
-	elapsed := time.Since(start)
-	log.Printf("Binomial took %ds", elapsed.Nanoseconds()/1000000000)
+	return nil
 }
 
 func timeTrack(start time.Time, name string) {
 	elapsed := time.Since(start)
-	log.Printf("%s took %ds", name, elapsed.Nanoseconds()/1000000000)
+	slog.Info(name+" took", "seconds", elapsed.Nanoseconds()/1000000000)
 }
 
 func factorial(n *big.Int) (result *big.Int) {
@@ -92,72 +610,519 @@ func factorial(n *big.Int) (result *big.Int) {
 	return n
 }
 
-func genAllWords() []string {
-	f, err := os.Open(*wordsFile)
+// loadIndex reads the dictionary at path and indexes the words that can be
+// answers under the current options, with their frequencies from -freq_file,
+// or failing that, the weights of a TSV dictionary.
+func loadIndex(path string) (*wordIndex, error) {
+	words, weights, _, err := readDictionary(path)
 	if err != nil {
-		log.Fatalf("Open(%q): %v", *wordsFile, err)
+		return nil, err
 	}
-	r := bufio.NewReader(f)
-	allWords := []string{}
-	for {
-		l, err := r.ReadBytes('\n')
-		if err == io.EOF {
-			break
-		}
+	idx := newWordIndex(words)
+	if *freqFile == "" && weights != nil {
+		idx.setFreqs(weights)
+		return idx, nil
+	}
+	if err := idx.loadFreqs(*freqFile); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// LoadStats counts the dictionary words kept, and those rejected by each
+// filter.
+type LoadStats struct {
+	Read           int // non-empty lines, or JSON words, read
+	Kept           int
+	TooShort       int // fewer than -min_word_len letters
+	TooLong        int // more than -max_word_len letters
+	NotInAlphabet  int // letters outside -alphabet, such as capitals or punctuation
+	TooManyLetters int // more than -num_letters different letters
+	TooFewLetters  int // fewer than -min_distinct_in_word different letters
+	Duplicates     int // repeats of a kept word, such as "Polish" after "polish" with -lowercase
+	Excluded       int // listed in -exclude_words_file
+}
+
+// readWords reads the dictionary at path and returns the words that can be
+// answers under the current options, with counts of why the others were
+// rejected.
+func readWords(path string) ([]string, LoadStats, error) {
+	words, _, stats, err := readDictionary(path)
+	return words, stats, err
+}
+
+// readDictionary is like readWords, but also returns the weight of each word
+// if the dictionary is in TSV format, and nil otherwise.
+func readDictionary(path string) (words []string, weights map[string]float64, stats LoadStats, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, LoadStats{}, fmt.Errorf("Open(%q): %w", path, err)
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	format := *dictFormat
+	if format == "auto" {
+		format = sniffDictFormat(br)
+	}
+	switch format {
+	case "text":
+		words, stats, err = LoadDictionary(br, opts)
+	case "json":
+		words, stats, err = LoadJSONDictionary(br, opts)
+	case "tsv":
+		words, weights, stats, err = LoadTSVDictionary(br, opts)
+	default:
+		return nil, nil, LoadStats{}, fmt.Errorf("unknown -dict_format %q, want text, json, tsv or auto", *dictFormat)
+	}
+	if err != nil {
+		return nil, nil, stats, dictionaryError(path, err)
+	}
+	if *excludeWordsFile != "" {
+		excluded, err := readExcludeWords(*excludeWordsFile)
 		if err != nil {
-			log.Fatalf("ReadBytes: %v", err)
+			return nil, nil, stats, err
 		}
-		w := string(l)
-		w = strings.TrimSpace(w)
-		// Words must be >3 letters.
-		if len(w) < 5 {
-			continue
+		if words, err = stats.exclude(words, excluded); err != nil {
+			return nil, nil, stats, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	slog.Info("Matching words", "count", len(words))
+	return words, weights, stats, nil
+}
+
+// sniffDictFormat returns the format of the dictionary being read by br, for
+// -dict_format auto: json if it starts with [ or {, tsv if its first line
+// has a tab, and text otherwise.
+func sniffDictFormat(br *bufio.Reader) string {
+	if looksLikeJSON(br) {
+		return "json"
+	}
+	b, _ := br.Peek(512)
+	first, _, _ := bytes.Cut(b, []byte("\n"))
+	if bytes.ContainsRune(first, '\t') {
+		return "tsv"
+	}
+	return "text"
+}
+
+// dictionaryError adds the path of the dictionary to err, an error from
+// loading it.
+func dictionaryError(path string, err error) error {
+	if errors.Is(err, ErrEmptyDictionary) {
+		return fmt.Errorf("%w loaded from %q", err, path)
+	}
+	return fmt.Errorf("%s: %w", path, err)
+}
+
+// LoadDictionary reads a dictionary of one word per line from r and returns
+// the words that can be answers under opts, in order, with counts of why the
+// others were rejected. It is an error if r is not UTF-8 or no words are
+// kept.
+func LoadDictionary(r io.Reader, opts Options) (words []string, stats LoadStats, err error) {
+	words = []string{}
+	err = eachLine(r, func(_ int, w string) error {
+		if w, ok := stats.filter(w, opts); ok {
+			words = append(words, w)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, stats, err
+	}
+	return stats.kept(words)
+}
+
+// eachLine calls f with each non-blank line of r and its line number, with
+// surrounding space trimmed, until f returns an error. It is an error if r
+// is not UTF-8.
+func eachLine(r io.Reader, f func(line int, l string) error) error {
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		l, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("ReadBytes: %v", err)
+		}
+		// The last line may not end in a newline.
+		if err == io.EOF && len(l) == 0 {
+			return nil
+		}
+		// Reject non-UTF-8 dictionaries (e.g. Latin-1) rather than silently
+		// dropping every word with an accented letter.
+		if !utf8.Valid(l) {
+			return fmt.Errorf("line %d: %w in %q; convert the dictionary to UTF-8", line, ErrInvalidUTF8, strings.TrimSpace(string(l)))
+		}
+		if s := strings.TrimSpace(string(l)); s != "" {
+			if err := f(line, s); err != nil {
+				return err
+			}
 		}
-		// Words must be lowercase, no punctuation.
-		if !containsOnly(w, alphabet) {
+	}
+}
+
+// filter counts the dictionary word w as read, and returns it, normalized
+// with -normalize and folded with -lowercase, and whether it can be an
+// answer under opts. If not, it counts why.
+func (stats *LoadStats) filter(w string, opts Options) (string, bool) {
+	w = opts.normalize(w)
+	if opts.Lowercase {
+		w = foldToAlphabet(w, opts.Alphabet)
+	}
+	stats.Read++
+	// Words must be at least -min_word_len letters, and at most
+	// -max_word_len.
+	n := utf8.RuneCountInString(w)
+	if n < opts.MinWordLen {
+		stats.TooShort++
+		return w, false
+	}
+	if opts.MaxWordLen != 0 && n > opts.MaxWordLen {
+		stats.TooLong++
+		return w, false
+	}
+	// Words must be lowercase, no punctuation.
+	if !containsOnly(w, opts.Alphabet) {
+		stats.NotInAlphabet++
+		return w, false
+	}
+	// Words must contain <=N unique letters.
+	if !hasAtMostLetters(w, opts.Alphabet, opts.NumLetters) {
+		stats.TooManyLetters++
+		return w, false
+	}
+	if bits.OnesCount32(letterMask(w, opts.Alphabet)) < opts.MinDistinctLetters {
+		stats.TooFewLetters++
+		return w, false
+	}
+	return w, true
+}
+
+// kept drops repeated words from words, which have been through filter and
+// so already folded by -lowercase, and returns the rest with stats, or
+// ErrEmptyDictionary if there are none. The first of each word is kept.
+func (stats *LoadStats) kept(words []string) ([]string, LoadStats, error) {
+	seen := make(map[string]bool, len(words))
+	unique := words[:0]
+	for _, w := range words {
+		if seen[w] {
+			stats.Duplicates++
 			continue
 		}
-		// Words must contain <=N unique letters.
-		if !hasAtMostLetters(w, *numLetters) {
+		seen[w] = true
+		unique = append(unique, w)
+	}
+	words = unique
+	stats.Kept = len(words)
+	if len(words) == 0 {
+		return nil, *stats, ErrEmptyDictionary
+	}
+	return words, *stats, nil
+}
+
+// foldToAlphabet returns w with each letter not in alphabet replaced by a
+// case variant of it that is, so "École" becomes "école" with an alphabet
+// containing é. Letters with no variant in alphabet are lowercased.
+//
+// Only one-to-one case mappings are tried: "ß" won't become "ss", and
+// locale rules like Turkish dotted and dotless I aren't applied.
+func foldToAlphabet(w, alphabet string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(alphabet, r) {
+			return r
+		}
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			if strings.ContainsRune(alphabet, f) {
+				return f
+			}
+		}
+		return unicode.ToLower(r)
+	}, w)
+}
+
+// hasAtMostLetters reports whether s uses at most n different letters. s
+// must contain only letters of alphabet; see containsOnly.
+func hasAtMostLetters(s, alphabet string, n int) bool {
+	return bits.OnesCount32(letterMask(s, alphabet)) <= n
+}
+
+// readLetterSets returns the letter sets requested with -letters and
+// -letters_file, in that order. Every set is validated with validateLetters.
+func readLetterSets() ([]string, error) {
+	sets := []string{}
+	if *letters != "" {
+		sets = append(sets, *letters)
+	}
+	if *lettersFile != "" {
+		f, err := os.Open(*lettersFile)
+		if err != nil {
+			return nil, fmt.Errorf("Open(%q): %v", *lettersFile, err)
+		}
+		defer f.Close()
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			l := strings.TrimSpace(sc.Text())
+			if l == "" {
+				continue
+			}
+			sets = append(sets, l)
+		}
+		if err := sc.Err(); err != nil {
+			return nil, fmt.Errorf("reading %q: %v", *lettersFile, err)
+		}
+	}
+	for _, s := range sets {
+		if err := validateLetters(s, opts.NumLetters); err != nil {
+			return nil, err
+		}
+	}
+	return sets, nil
+}
+
+// validateLetters reports whether s is a usable puzzle: exactly n distinct
+// letters, all from the alphabet.
+func validateLetters(s string, n int) error {
+	if l := utf8.RuneCountInString(s); l != n {
+		return fmt.Errorf("letter set %q has %d letters, want %d", s, l, n)
+	}
+	if !containsOnly(s, opts.Alphabet) {
+		return fmt.Errorf("letter set %q must contain only letters from %q", s, opts.Alphabet)
+	}
+	if hasAtMostLetters(s, opts.Alphabet, n-1) {
+		return fmt.Errorf("letter set %q has repeated letters, want %d distinct letters", s, n)
+	}
+	return nil
+}
+
+// canonicalLetters returns s with its center letters kept first and the
+// outer letters sorted, so every ordering of a puzzle has the same key. With
+// -centers above 1 the center letters are sorted too.
+func canonicalLetters(s string) string {
+	rs := []rune(s)
+	k := min(opts.Centers, len(rs))
+	centers, outer := rs[:k], rs[k:]
+	sort.Slice(centers, func(i, j int) bool { return centers[i] < centers[j] })
+	sort.Slice(outer, func(i, j int) bool { return outer[i] < outer[j] })
+	return string(rs)
+}
+
+// centerLetter returns the center letter of the letter set s, its first, or
+// with -centers N its first N letters, which every answer must use.
+func centerLetter(s string) string {
+	i := 0
+	for n := range s {
+		if i == opts.Centers {
+			return s[:n]
+		}
+		i++
+	}
+	return s
+}
+
+// centerChoices returns s once for each choice of center letters, with the
+// centers first. With one center these are the rotations of s.
+func centerChoices(s string) []string {
+	rs := []rune(s)
+	if opts.Centers == 1 {
+		choices := make([]string, len(rs))
+		for i := range rs {
+			choices[i] = string(rs[i:]) + string(rs[:i])
+		}
+		return choices
+	}
+	choices := []string{}
+	// Pick opts.Centers of the positions in rs, in order.
+	var pick func(start int, centers []rune, chosen uint64)
+	pick = func(start int, centers []rune, chosen uint64) {
+		if len(centers) == opts.Centers {
+			outer := []rune{}
+			for i, r := range rs {
+				if chosen&(1<<i) == 0 {
+					outer = append(outer, r)
+				}
+			}
+			choices = append(choices, string(centers)+string(outer))
+			return
+		}
+		for i := start; i < len(rs); i++ {
+			pick(i+1, append(centers[:len(centers):len(centers)], rs[i]), chosen|1<<i)
+		}
+	}
+	pick(0, nil, 0)
+	return choices
+}
+
+// emitStrings sends each of ss to out, then closes out.
+func emitStrings(ctx context.Context, ss []string, out chan<- string) {
+	defer close(out)
+	for _, s := range ss {
+		if !send(ctx, out, s) {
+			return
+		}
+	}
+}
+
+// skipLetters sends each letter set from in to out unless its resumeKey is
+// in done, then closes out.
+func skipLetters(ctx context.Context, in <-chan string, out chan<- string, done map[string]bool) {
+	defer close(out)
+	for s := range in {
+		if done[resumeKey(s)] {
 			continue
 		}
+		if !send(ctx, out, s) {
+			return
+		}
+	}
+}
 
-		allWords = append(allWords, w)
+// resumeKey returns the key of the letter set s in the done sets of
+// skipLetters: its canonical letters, or with -best_center, where the center
+// is only chosen after skipLetters, its letter mask, whatever the center.
+func resumeKey(s string) string {
+	if *bestCenter {
+		return strconv.FormatUint(uint64(letterMask(s, opts.Alphabet)), 16)
 	}
-	f.Close()
-	fmt.Println("Matching", len(allWords), "words")
-	return allWords
+	return canonicalLetters(s)
 }
 
-func hasAtMostLetters(s string, n int) bool {
-	cs := map[rune]struct{}{}
-	for _, c := range s {
-		cs[c] = struct{}{}
-		if len(cs) > n {
-			return false
+// vowelLetters sends each letter set from in to out if it has as many vowels
+// as -min_vowels and -max_vowels allow, then closes out.
+func vowelLetters(ctx context.Context, in <-chan string, out chan<- string) {
+	defer close(out)
+	for s := range in {
+		if !vowelsInRange(s) {
+			continue
+		}
+		if !send(ctx, out, s) {
+			return
 		}
 	}
-	return true
+}
+
+// vowelsInRange reports whether the letter set s has at least -min_vowels
+// and at most -max_vowels vowels.
+func vowelsInRange(s string) bool {
+	vowels := "aeiou"
+	if opts.VowelY {
+		vowels += "y"
+	}
+	n := 0
+	for _, r := range s {
+		if strings.ContainsRune(vowels, r) {
+			n++
+		}
+	}
+	return n >= opts.MinVowels && (opts.MaxVowels == 0 || n <= opts.MaxVowels)
+}
+
+// send sends s to out. It returns false if ctx is done first.
+func send(ctx context.Context, out chan<- string, s string) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	select {
+	case out <- s:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
 
 // genAllStrings generates all unique strings of length n and sends them to
-// out.
-func genAllStrings(n int, out chan<- string) {
-	for _, c := range alphabet {
+// out. It stops early if ctx is done.
+//
+// Each letter's strings are generated by a goroutine while the previous
+// letter's are being sent, unless -gen_parallel of them are already running,
+// in which case they are generated in this one.
+func genAllStrings(ctx context.Context, n int, out chan<- string) {
+	genStrings(ctx, []rune(opts.Alphabet), n, genSem, out)
+}
+
+// genStrings sends each string of n letters from letters to out, in
+// alphabet order, then closes out. The goroutines it starts take a slot in
+// sem while they run, if sem isn't nil. They are given letters and sem
+// rather than reading opts and genSem, which may have changed by the time a
+// goroutine starts or releases its slot.
+func genStrings(ctx context.Context, letters []rune, n int, sem chan struct{}, out chan<- string) {
+	defer close(out)
+	for i, c := range letters {
+		if ctx.Err() != nil {
+			return
+		}
 		if n == 1 {
-			out <- string(c)
+			if !send(ctx, out, string(c)) {
+				return
+			}
 			continue
 		}
 
+		// Every later letter can follow c, so each set is sent once.
+		later := letters[i+1:]
 		ch := make(chan string, 1000)
-		go genAllStrings(n-1, ch)
+		select {
+		case sem <- struct{}{}:
+			go func() {
+				defer func() { <-sem }()
+				genStrings(ctx, later, n-1, sem, ch)
+			}()
+		default:
+			if sem == nil {
+				go genStrings(ctx, later, n-1, sem, ch)
+				break
+			}
+			if !eachString(later, n-1, func(rest string) bool {
+				return send(ctx, out, string(c)+rest)
+			}) {
+				return
+			}
+			continue
+		}
 		for rest := range ch {
-			if rest[0] > byte(c) {
-				out <- string(c) + rest
+			if !send(ctx, out, string(c)+rest) {
+				return
 			}
 		}
 	}
-	close(out)
+}
+
+// genSem limits the goroutines genAllStrings starts to -gen_parallel. It is
+// nil if there is no limit.
+var genSem chan struct{}
+
+// eachString calls f with each string of n letters from letters, in the
+// order genAllStrings sends them, until f returns false. It reports whether f
+// always returned true.
+func eachString(letters []rune, n int, f func(string) bool) bool {
+	if n == 0 {
+		return f("")
+	}
+	for i, c := range letters {
+		if !eachString(letters[i+1:], n-1, func(rest string) bool { return f(string(c) + rest) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// shuffleStrings reads every string from in, then sends them to out in a
+// random order determined by seed. If seed is 0, a seed is picked from the
+// clock and logged. It stops early if ctx is done.
+func shuffleStrings(ctx context.Context, seed int64, in <-chan string, out chan<- string) {
+	defer close(out)
+	ss := []string{}
+	for s := range in {
+		ss = append(ss, s)
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	slog.Info("Shuffling letter sets", "count", len(ss), "seed", seed)
+	r := rand.New(rand.NewSource(seed))
+	r.Shuffle(len(ss), func(i, j int) { ss[i], ss[j] = ss[j], ss[i] })
+	for _, s := range ss {
+		if !send(ctx, out, s) {
+			return
+		}
+	}
 }
 
 // rotate emits rotated versions of the string.
@@ -170,20 +1135,29 @@ func genAllStrings(n int, out chan<- string) {
 // - efgabcd
 // - fgabcde
 // - gabcdef
-func rotate(in <-chan string, out chan<- string) {
+//
+// Each string of n letters yields n rotations, one per center letter, so a
+// single letter yields just itself. With -centers above 1 it sends every
+// choice of center letters instead; see centerChoices.
+func rotate(ctx context.Context, in <-chan string, out chan<- string) {
+	defer close(out)
 	for s := range in {
-		for i := 0; i < len(s); i++ {
-			first, rest := s[:i], s[i:]
-			out <- rest + first
+		for _, c := range centerChoices(s) {
+			if !send(ctx, out, c) {
+				return
+			}
 		}
 	}
-	close(out)
 }
 
 type puzzle struct {
 	letters string
 	words   []string
 	maxPts  int
+
+	// difficulty maps each answer to its difficulty; see
+	// wordIndex.difficulty. It is nil without a -freq_file.
+	difficulty map[string]string
 }
 
 func containsOnly(s, target string) bool {
@@ -199,93 +1173,341 @@ func containsOnly(s, target string) bool {
 	return true
 }
 
-// matchWords emits all words that match in (with spelling bee semantics).
-func matchWords(allWords []string, in <-chan string, out chan<- puzzle) {
+// letterMask returns a bitmask with bit i set if s contains the i'th letter of
+// alphabet. Letters outside alphabet are ignored.
+func letterMask(s, alphabet string) uint32 {
+	var m uint32
+	for _, r := range s {
+		m |= letterBit(r, alphabet)
+	}
+	return m
+}
 
+// letterBit returns 1<<i if r is the i'th letter of alphabet, or 0 if it
+// isn't in alphabet.
+func letterBit(r rune, alphabet string) uint32 {
+	// Fast path for the default alphabet.
+	if 'a' <= r && r <= 'z' && alphabet == defaultAlphabet {
+		return 1 << (r - 'a')
+	}
+	i := 0
+	for _, a := range alphabet {
+		if a == r {
+			return 1 << i
+		}
+		i++
+	}
+	return 0
+}
+
+// matchWords emits all words that match in (with spelling bee semantics).
+// It stops early if ctx is done.
+func matchWords(ctx context.Context, idx *wordIndex, in <-chan string, out chan<- puzzle) {
 	for s := range in {
-		runes := map[rune]struct{}{}
-		for _, c := range s {
-			runes[c] = struct{}{}
+		var p puzzle
+		var ok bool
+		if *bestCenter {
+			p, ok = bestCenterPuzzle(idx, s)
+		} else {
+			p, ok = makePuzzle(idx, s)
 		}
+		if !ok {
+			continue
+		}
+		if reason := finalCheck(p); reason != "" {
+			rejectLetters(s, p, reason)
+			continue
+		}
+		select {
+		case out <- p:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
 
-		words := []string{}
-		for _, word := range allWords {
-			// Words must contain the first character.
-			if !strings.Contains(word, string(s[0])) {
-				continue
+// bestCenterPuzzle builds the puzzle for each choice of center in s and
+// returns the one with the most answers, or the most points if
+// -best_center_by is points. Ties go to the center that comes first in s.
+// Like makePuzzle, it counts s once, as generated or as rejected; if every
+// center is rejected, the reason given is that of s as it is.
+func bestCenterPuzzle(idx *wordIndex, s string) (puzzle, bool) {
+	score := func(p puzzle) int { return len(p.words) }
+	if *bestCenterBy == "points" {
+		score = func(p puzzle) int { return p.maxPts }
+	}
+	var best, first puzzle
+	found, firstReason := false, ""
+	for i, c := range centerChoices(s) {
+		p, reason := checkPuzzle(idx, c)
+		if reason != "" {
+			if i == 0 {
+				first, firstReason = p, reason
 			}
+			continue
+		}
+		if !found || score(p) > score(best) {
+			best, found = p, true
+		}
+	}
+	if !found {
+		rejectLetters(s, first, firstReason)
+		return puzzle{}, false
+	}
+	return acceptPuzzle(idx, best), true
+}
 
-			// Words must contain only letters in this set.
-			if containsOnly(word, s) {
-				words = append(words, word)
-			}
+// inPointsRange reports whether a puzzle worth maxPts is within -min_points
+// and -max_points.
+func inPointsRange(maxPts int) bool {
+	return maxPts >= opts.MinPoints && (opts.MaxPoints == 0 || maxPts <= opts.MaxPoints)
+}
+
+// finalCheck returns why the puzzle p, already accepted by makePuzzle, is
+// rejected by the checks matchWords makes on the chosen puzzle, or "" if it
+// isn't.
+func finalCheck(p puzzle) string {
+	switch {
+	case !inPointsRange(p.maxPts):
+		return "points out of range"
+	case !highEnoughAverage(p):
+		return "average points too low"
+	case startLetters(p.words) < opts.MinStartLetters:
+		return "too few start letters"
+	}
+	return ""
+}
+
+// startLetters returns how many different letters words start with.
+func startLetters(words []string) int {
+	seen := map[rune]bool{}
+	for _, w := range words {
+		r, _ := utf8.DecodeRuneInString(w)
+		seen[r] = true
+	}
+	return len(seen)
+}
+
+// highEnoughAverage reports whether p's answers average at least
+// -min_avg_points, as scored by ScoreWord.
+func highEnoughAverage(p puzzle) bool {
+	return opts.MinAvgPoints <= 0 || float64(p.maxPts) >= opts.MinAvgPoints*float64(len(p.words))
+}
+
+// makePuzzle builds the puzzle for the letter set s, whose first letter is the
+// center, keyed by canonicalLetters(s). It reports false if s doesn't make a
+// valid puzzle.
+func makePuzzle(idx *wordIndex, s string) (puzzle, bool) {
+	p, reason := checkPuzzle(idx, s)
+	if reason != "" {
+		rejectLetters(s, p, reason)
+		return puzzle{}, false
+	}
+	return acceptPuzzle(idx, p), true
+}
+
+// acceptPuzzle counts p, which checkPuzzle accepted, as generated, and
+// returns it with the difficulty of its answers set if there are
+// frequencies.
+func acceptPuzzle(idx *wordIndex, p puzzle) puzzle {
+	puzzlesGenerated.Add(1)
+	if idx.freqs != nil {
+		p.difficulty = make(map[string]string, len(p.words))
+		for _, w := range p.words {
+			p.difficulty[w] = idx.difficulty(w)
 		}
+	}
+	return p
+}
 
-		// This combination of letters doesn't produce enough answers.
-		if len(words) < 10 {
-			if *v {
-				// fmt.Print(string(s) + "\n")
-			}
-			continue
+// checkPuzzle builds the puzzle for the letter set s like makePuzzle, and
+// returns why s doesn't make a valid puzzle, or "" if it does. Unlike
+// makePuzzle, it neither logs nor counts s. The puzzle's words are set
+// even if it is rejected; its points only once the words pass -min_words.
+//
+// The checks run in order: -min_words first, then the pangram and
+// -pangram_freq checks, then -require_near_pangram and -min_easy_words, so a
+// set short of answers is always "too few words".
+func checkPuzzle(idx *wordIndex, s string) (puzzle, string) {
+	// Words must contain the first character, unless -require_center=false,
+	// and only letters in this set.
+	var center uint32
+	if opts.RequireCenter {
+		center = letterMask(centerLetter(s), opts.Alphabet)
+	}
+	words := idx.match(letterMask(s, opts.Alphabet), center)
+	p := puzzle{letters: canonicalLetters(s), words: words}
+
+	// This combination of letters doesn't produce enough answers.
+	if len(words) < opts.MinWords {
+		return p, "too few words"
+	}
+
+	// Score the puzzle and ensure at least one answer uses all letters,
+	// and is common enough with -pangram_freq.
+	pangrams, someCommon, someNear, easy := 0, false, false, 0
+	for _, w := range words {
+		if isPangram(w, s) {
+			pangrams++
+			someCommon = someCommon || idx.common(w)
+		} else if opts.RequireNearPangram {
+			someNear = someNear || isNearPangram(w, s)
+		}
+		if opts.MinEasyWords > 0 && idx.easy(w) {
+			easy++
 		}
+		p.maxPts += ScoreWord(w, s)
+	}
+	if pangrams == 0 {
+		return p, "no pangram"
+	}
+	if opts.MaxPangrams > 0 && pangrams > opts.MaxPangrams {
+		return p, "too many pangrams"
+	}
+	if !someCommon {
+		return p, "no common pangram"
+	}
+	if opts.RequireNearPangram && !someNear {
+		return p, "no near pangram"
+	}
+	if easy < opts.MinEasyWords {
+		return p, "too few easy words"
+	}
+	return p, ""
+}
 
-		// Score the puzzle and ensure at least one answer uses all letters.
-		someContainsAll := false
-		maxPts := 0
-		for _, w := range words {
-			containsAll := true
-			for _, let := range s {
-				if !strings.ContainsRune(w, let) {
-					containsAll = false
-				}
-			}
-			if containsAll {
-				maxPts += 3
-				someContainsAll = true
-			} else {
-				maxPts += 1
+// errFirstN is why generation stops once -first_n puzzles are written.
+var errFirstN = errors.New("wrote -first_n puzzles")
+
+// firstPuzzles passes the first n puzzles from in to the channel it returns,
+// then calls stop and discards the rest of in.
+func firstPuzzles(in <-chan puzzle, n int, stop func()) <-chan puzzle {
+	out := make(chan puzzle)
+	go func() {
+		defer close(out)
+		sent := 0
+		for p := range in {
+			if sent == n {
+				continue
 			}
-		}
-		if !someContainsAll {
-			if *v {
-				fmt.Print("2")
+			out <- p
+			if sent++; sent == n {
+				stop()
 			}
-			continue
 		}
+	}()
+	return out
+}
 
-		out <- puzzle{
-			letters: s,
-			words:   words,
-			maxPts:  maxPts,
-		}
-	}
+// partialError reports that some puzzles could not be written, though the
+// run otherwise completed.
+type partialError struct {
+	failed int
+	first  error
 }
 
-func writePuzzles(in <-chan puzzle) {
+func (e *partialError) Error() string {
+	return fmt.Sprintf("%d puzzles could not be written, the first because: %v", e.failed, e.first)
+}
+
+// writePuzzles writes each puzzle from in with pw until in is closed or ctx
+// is done, then closes pw, flushing any buffered output. A puzzle is only
+// ever written whole: once ctx is done no more are started, and the rest of
+// in is discarded. It returns a manifest entry for each puzzle written.
+// Puzzles that can't be written are logged and skipped, and reported at the
+// end with a *partialError, unless none could be written, which is a plain
+// error.
+func writePuzzles(ctx context.Context, in <-chan puzzle, pw puzzleWriter) ([]manifestEntry, error) {
 	t := time.Tick(time.Second)
+	var written manifest
+	var partial *partialError
+	finish := func() ([]manifestEntry, error) {
+		if err := pw.close(); err != nil {
+			return written.list(), err
+		}
+		switch {
+		case partial != nil && len(written.list()) == 0:
+			return nil, fmt.Errorf("no puzzles could be written; %d failed, the first because: %w", partial.failed, partial.first)
+		case partial != nil:
+			return written.list(), partial
+		}
+		return written.list(), nil
+	}
 	for {
 		select {
+		case <-ctx.Done():
+			// Keep the stages upstream from blocking on a send nobody reads.
+			go func() {
+				for range in {
+				}
+			}()
+			return finish()
 		case p, ok := <-in:
 			if !ok {
-				return
+				return finish()
 			}
-			fn := p.letters + ".txt"
-			f, err := os.Create("./puzzels/" + fn)
-			if err != nil {
-				log.Fatalf("Create(%q): %v", fn, err)
+			if *groupAnagrams {
+				p.words = anagramOrder(p.words)
 			}
-			for _, w := range p.words {
-				fmt.Fprintln(f, w)
+			fn, err := pw.write(ctx, p)
+			if err != nil {
+				err = fmt.Errorf("write %s: %w", p.letters, err)
+				slog.Error("Write failed", "err", err)
+				if partial == nil {
+					partial = &partialError{first: err}
+				}
+				partial.failed++
+				continue
 			}
-			fmt.Fprintln(f, p.maxPts)
 			if *v {
 				fmt.Println("wrote", p.letters)
 			}
-			f.Close()
+			written.add(p, fn)
 		case <-t:
 			if *v {
 				fmt.Print("%")
 			}
 		}
 	}
-}
\ No newline at end of file
+}
+
+// A write that fails with a transient error is retried up to writeRetries
+// times, waiting writeBackoff before the first retry and twice as long
+// before each one after.
+const (
+	writeRetries = 3
+	writeBackoff = 100 * time.Millisecond
+)
+
+// retryWrite calls write, which writes the puzzle with the given letters to
+// a file of its own, and retries it while it fails with a transient error.
+// write must replace the file it writes, so a retry leaves no trace of the
+// failed attempt. It gives up, returning the last error, if ctx is done
+// while waiting to retry.
+func retryWrite(ctx context.Context, letters string, write func() error) error {
+	backoff := writeBackoff
+	for i := 0; ; i++ {
+		err := write()
+		if err == nil || i == writeRetries || !isTransient(err) {
+			return err
+		}
+		slog.Warn("Write failed, retrying", "letters", letters, "err", err, "after", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// isTransient reports whether err is one that network filesystems return
+// for failures that may not happen again.
+func isTransient(err error) bool {
+	for _, errno := range []error{syscall.EAGAIN, syscall.EINTR, syscall.EIO, syscall.ESTALE, syscall.ETIMEDOUT} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
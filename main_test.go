@@ -0,0 +1,1092 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"maps"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nickgraffis/spelling-bee/spellingbeetest"
+)
+
+// setOpts sets opts to o for the rest of the test.
+func setOpts(t testing.TB, o Options) {
+	t.Helper()
+	old := opts
+	opts = o
+	t.Cleanup(func() { opts = old })
+}
+
+// setFlag sets the flag variable p to v for the rest of the test.
+func setFlag[T any](t testing.TB, p *T, v T) {
+	t.Helper()
+	old := *p
+	*p = v
+	t.Cleanup(func() { *p = old })
+}
+
+// writeTestFile writes lines to the file name in a temporary directory and
+// returns its path.
+func writeTestFile(t *testing.T, name string, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// captureOutput returns what f writes to stdout, and what it logs, at any
+// level, with log or slog.
+func captureOutput(t *testing.T, f func()) (stdout, logged string) {
+	t.Helper()
+	var logBuf bytes.Buffer
+	log.SetOutput(&logBuf)
+	defer log.SetOutput(os.Stderr)
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := os.Stdout
+	os.Stdout = w
+	out := make(chan []byte)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- b
+	}()
+	defer func() {
+		os.Stdout = old
+		w.Close()
+		stdout, logged = string(<-out), logBuf.String()
+	}()
+	f()
+	return
+}
+
+// repoDictionary returns the words of dict.txt that can be answers.
+func repoDictionary(tb testing.TB) []string {
+	tb.Helper()
+	words, _, err := readWords("dict.txt")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return words
+}
+
+// testIndex returns an index of the words that can be answers under the
+// current opts.
+func testIndex(t *testing.T, words ...string) *wordIndex {
+	t.Helper()
+	kept, _, err := LoadDictionary(strings.NewReader(strings.Join(words, "\n")), opts)
+	if err != nil {
+		t.Fatalf("LoadDictionary: %v", err)
+	}
+	return newWordIndex(kept)
+}
+
+// sampleIndex returns an index of spellingbeetest.SampleDictionary.
+func sampleIndex(t *testing.T) *wordIndex {
+	t.Helper()
+	return testIndex(t, spellingbeetest.SampleDictionary()...)
+}
+
+// chdirPuzzles changes to a new temporary directory with a puzzels
+// directory for writePuzzles, and returns the puzzels directory.
+func chdirPuzzles(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "puzzels"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return filepath.Join(dir, "puzzels")
+}
+
+func TestValidateLetters(t *testing.T) {
+	for _, tc := range []struct {
+		s, want string
+	}{
+		{"aelprst", ""},
+		{"aelprsa", "repeated letters"},
+		{"aelprs", "has 6 letters"},
+		{"aelprsT", "only letters"},
+	} {
+		err := validateLetters(tc.s, 7)
+		switch {
+		case tc.want == "" && err != nil:
+			t.Errorf("validateLetters(%q) = %v, want nil", tc.s, err)
+		case tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)):
+			t.Errorf("validateLetters(%q) = %v, want an error containing %q", tc.s, err, tc.want)
+		}
+	}
+}
+
+func TestReadLetterSetsRejectsRepeatedLetters(t *testing.T) {
+	setFlag(t, letters, "aelprsa")
+	if sets, err := readLetterSets(); err == nil {
+		t.Errorf("readLetterSets() with -letters aelprsa = %q, want an error", sets)
+	}
+}
+
+func TestGenAllStringsStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan string)
+	// Generating every letter set takes minutes.
+	go genAllStrings(ctx, 7, out)
+	for i := 0; i < 3; i++ {
+		<-out
+	}
+	cancel()
+	done := make(chan bool)
+	go func() {
+		for range out {
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("genAllStrings still sending 10s after its context was cancelled")
+	}
+}
+
+func TestMatchWordsStopsWhenCancelled(t *testing.T) {
+	idx := sampleIndex(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	in := make(chan string, 1)
+	in <- "aelprst"
+	close(in)
+	done := make(chan bool)
+	go func() {
+		// Nothing reads the puzzle made.
+		matchWords(ctx, idx, in, make(chan puzzle))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("matchWords still waiting to send a puzzle 10s after its context was cancelled")
+	}
+}
+
+func TestWritePuzzlesKeepsPuzzlesWritten(t *testing.T) {
+	setFlag(t, v, false)
+	dir := t.TempDir()
+	in := make(chan puzzle, 2)
+	in <- puzzle{letters: "aelprst", words: []string{"plaster"}, maxPts: 3}
+	in <- puzzle{letters: "paelrst", words: []string{"plaster"}, maxPts: 3}
+	close(in)
+	if written, err := writePuzzles(context.Background(), in, newTxtWriter(dir, false, false, false, 1)); err != nil || len(written) != 2 {
+		t.Errorf("writePuzzles wrote %d puzzles (%v), want 2", len(written), err)
+	}
+	for _, f := range []string{"aelprst.txt", "paelrst.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, f)); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestLoadDictionaryRejectsInvalidUTF8(t *testing.T) {
+	// "école" in Latin-1.
+	_, _, err := LoadDictionary(strings.NewReader("plate\n\xe9cole\n"), DefaultOptions())
+	if err == nil || !strings.Contains(err.Error(), "line 2: invalid UTF-8") {
+		t.Errorf("LoadDictionary of Latin-1 = %v, want an invalid UTF-8 error giving the line", err)
+	}
+}
+
+func TestRunDispatchesSubcommands(t *testing.T) {
+	var called string
+	var calledArgs []string
+	for _, c := range commands {
+		old := c.run
+		c.run = func(args []string) error {
+			called, calledArgs = c.name, args
+			return nil
+		}
+		t.Cleanup(func() { c.run = old })
+	}
+	setFlag(t, parallel, *parallel)
+	setOpts(t, opts)
+	setFlag(t, addr, *addr)
+	setFlag(t, dryRun, *dryRun)
+	setFlag(t, renderSize, *renderSize)
+	setFlag(t, outDir, *outDir)
+
+	for _, tc := range []struct {
+		args []string
+		want string
+		set  func() bool
+	}{
+		{[]string{"generate", "-parallel", "3", "extra"}, "generate", func() bool { return *parallel == 3 }},
+		{[]string{"-parallel", "5", "extra"}, "generate", func() bool { return *parallel == 5 }},
+		{[]string{"serve", "-addr", ":9090", "-num_letters", "5", "extra"}, "serve", func() bool { return *addr == ":9090" && opts.NumLetters == 5 }},
+		{[]string{"clean", "-dry_run", "extra"}, "clean", func() bool { return *dryRun }},
+		{[]string{"render", "-size", "64", "extra"}, "render", func() bool { return *renderSize == 64 }},
+		{[]string{"rescore", "-num_letters", "5", "extra"}, "rescore", func() bool { return opts.NumLetters == 5 }},
+		{[]string{"verify", "-out_dir", "puzzles", "extra"}, "verify", func() bool { return *outDir == "puzzles" }},
+		{[]string{"version", "extra"}, "version", func() bool { return true }},
+	} {
+		called, calledArgs = "", nil
+		if err := run(tc.args); err != nil {
+			t.Errorf("run(%q): %v", tc.args, err)
+			continue
+		}
+		if called != tc.want || len(calledArgs) != 1 || calledArgs[0] != "extra" {
+			t.Errorf("run(%q) ran %s with %q, want %s with [\"extra\"]", tc.args, called, calledArgs, tc.want)
+		}
+		if !tc.set() {
+			t.Errorf("run(%q) didn't set the flags", tc.args)
+		}
+	}
+	if err := run([]string{"frobnicate"}); err == nil {
+		t.Error("run(frobnicate) succeeded, want an unknown command error")
+	}
+}
+
+func TestQuietOnlyPrintsErrors(t *testing.T) {
+	t.Cleanup(func() { slog.SetLogLoggerLevel(slog.LevelInfo) })
+	setFlag(t, quiet, false)
+	setFlag(t, v, true)
+	setFlag(t, wordsFile, *wordsFile)
+	setFlag(t, letters, *letters)
+	dict := writeTestFile(t, "dict.txt", spellingbeetest.SampleDictionary()...)
+	dir := chdirPuzzles(t)
+
+	stdout, logged := captureOutput(t, func() {
+		if err := run([]string{"-quiet", "-words_file", dict, "-letters", "aelprst"}); err != nil {
+			t.Errorf("run: %v", err)
+		}
+	})
+	if stdout != "" || logged != "" {
+		t.Errorf("with -quiet, a successful run printed %q and logged %q, want nothing", stdout, logged)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Errorf("with -quiet, wrote %d files, want 1", len(files))
+	}
+
+	stdout, logged = captureOutput(t, func() {
+		if err := run([]string{"-quiet", "-words_file", dict, "-letters", "aelprsa"}); err == nil {
+			t.Error("run with -letters aelprsa succeeded, want an error")
+		}
+	})
+	if stdout != "" || logged != "" {
+		t.Errorf("with -quiet, a failing run printed %q and logged %q, want only the error returned", stdout, logged)
+	}
+}
+
+func TestCanonicalLetters(t *testing.T) {
+	if a, b := canonicalLetters("aelprst"), canonicalLetters("atsrple"); a != b || a != "aelprst" {
+		t.Errorf("canonicalLetters gave %q and %q for two orderings, want aelprst for both", a, b)
+	}
+	if got := canonicalLetters("paelrst"); got != "paelrst" {
+		t.Errorf("canonicalLetters(paelrst) = %q, want the center kept first", got)
+	}
+	if p, ok := makePuzzle(sampleIndex(t), "atsrple"); !ok || p.letters != "aelprst" {
+		t.Errorf("makePuzzle(atsrple) = %q, %t, want the puzzle keyed aelprst", p.letters, ok)
+	}
+
+	o := DefaultOptions()
+	o.Centers = 2
+	setOpts(t, o)
+	if a, b := canonicalLetters("paelrst"), canonicalLetters("aptsrle"); a != b || a != "apelrst" {
+		t.Errorf("with two centers, canonicalLetters gave %q and %q, want apelrst for both", a, b)
+	}
+}
+
+func TestMakePuzzleMatchesContainsOnly(t *testing.T) {
+	words := repoDictionary(t)
+	idx := newWordIndex(words)
+	for _, s := range []string{"aelprst", "paelrst", "taelprs", "eaginrt"} {
+		p, ok := makePuzzle(idx, s)
+		if !ok {
+			t.Errorf("makePuzzle(%q) made no puzzle", s)
+			continue
+		}
+		if want := linearMatch(words, s); !reflect.DeepEqual(p.words, want) {
+			t.Errorf("makePuzzle(%q) answers = %q, want %q", s, p.words, want)
+		}
+	}
+}
+
+func BenchmarkMakePuzzle(b *testing.B) {
+	idx := newWordIndex(repoDictionary(b))
+	sets := []string{"aelprst", "eacinrt", "odgnirt", "xbcdfgh"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		makePuzzle(idx, sets[i%len(sets)])
+	}
+}
+
+func TestDebugLogsRejectedLetters(t *testing.T) {
+	slog.SetLogLoggerLevel(slog.LevelDebug)
+	t.Cleanup(func() { slog.SetLogLoggerLevel(slog.LevelInfo) })
+	// Without "plaster", the sample dictionary has eleven answers for aelprst but no
+	// pangram.
+	idx := testIndex(t, slices.DeleteFunc(spellingbeetest.SampleDictionary(), func(w string) bool { return w == "plaster" })...)
+	for _, tc := range []struct {
+		s, want string
+	}{
+		{"aelprst", "no pangram"},
+		{"zebrast", "too few words"},
+	} {
+		_, logged := captureOutput(t, func() {
+			if _, ok := makePuzzle(idx, tc.s); ok {
+				t.Errorf("makePuzzle(%q) made a puzzle", tc.s)
+			}
+		})
+		if !strings.Contains(logged, "letters="+tc.s) || !strings.Contains(logged, tc.want) {
+			t.Errorf("with debug logging, makePuzzle(%q) logged %q, want the reason %q", tc.s, logged, tc.want)
+		}
+	}
+}
+
+// matchAll returns the puzzles matchWords makes from sets, in order.
+func matchAll(idx *wordIndex, sets ...string) []puzzle {
+	in, out := make(chan string, len(sets)), make(chan puzzle, len(sets))
+	for _, s := range sets {
+		in <- s
+	}
+	close(in)
+	matchWords(context.Background(), idx, in, out)
+	close(out)
+	ps := []puzzle{}
+	for p := range out {
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+// puzzleLetters returns the letters of each of ps.
+func puzzleLetters(ps []puzzle) []string {
+	ls := []string{}
+	for _, p := range ps {
+		ls = append(ls, p.letters)
+	}
+	return ls
+}
+
+func TestMatchWordsPointsRange(t *testing.T) {
+	idx := sampleIndex(t)
+	// Centered on a, the sample letters are worth 70 points; on p, 60.
+	for _, tc := range []struct {
+		min, max int
+		want     []string
+	}{
+		{0, 0, []string{"aelprst", "paelrst"}},
+		{65, 0, []string{"aelprst"}},
+		{0, 65, []string{"paelrst"}},
+		{60, 70, []string{"aelprst", "paelrst"}},
+		{61, 69, []string{}},
+	} {
+		o := DefaultOptions()
+		o.MinPoints, o.MaxPoints = tc.min, tc.max
+		setOpts(t, o)
+		if got := puzzleLetters(matchAll(idx, "aelprst", "paelrst")); !slices.Equal(got, tc.want) {
+			t.Errorf("with points from %d to %d, matchWords made %q, want %q", tc.min, tc.max, got, tc.want)
+		}
+	}
+}
+
+func TestEmptyDictionary(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	for _, lines := range [][]string{{}, {"lap", "Zebra", "don't"}} {
+		path := writeTestFile(t, "dict.txt", lines...)
+		_, _, err := readWords(path)
+		want := fmt.Sprintf("no valid words loaded from %q", path)
+		if !errors.Is(err, ErrEmptyDictionary) || err.Error() != want {
+			t.Errorf("readWords of a dictionary of %q = %v, want ErrEmptyDictionary: %s", lines, err, want)
+		}
+	}
+}
+
+func TestRequireCenter(t *testing.T) {
+	idx := sampleIndex(t)
+	// Only areal, alert, plaster and trees have an r, too few for a puzzle.
+	if p, ok := makePuzzle(idx, "raelpst"); ok {
+		t.Errorf("centered on r, the sample letters made a puzzle of %q, want none", p.words)
+	}
+	o := DefaultOptions()
+	o.RequireCenter = false
+	setOpts(t, o)
+	p, ok := makePuzzle(idx, "raelpst")
+	if !ok || len(p.words) != 13 {
+		t.Errorf("with -require_center=false, the sample letters have %d answers, want 13: %q", len(p.words), p.words)
+	}
+}
+
+// collectStrings returns every string sent on ch until it is closed.
+func collectStrings(ch <-chan string) []string {
+	ss := []string{}
+	for s := range ch {
+		ss = append(ss, s)
+	}
+	return ss
+}
+
+func TestGenAllStrings(t *testing.T) {
+	o := DefaultOptions()
+	o.Alphabet, o.NumLetters = "abcd", 3
+	setOpts(t, o)
+	out := make(chan string)
+	go genAllStrings(context.Background(), 3, out)
+	want := []string{"abc", "abd", "acd", "bcd"}
+	if got := collectStrings(out); !slices.Equal(got, want) {
+		t.Errorf("genAllStrings(3) over abcd = %q, want %q", got, want)
+	}
+}
+
+func TestVowelLetters(t *testing.T) {
+	for _, tc := range []struct {
+		alphabet string
+		set      func(o *Options)
+		want     []string
+	}{
+		{"abcdy", func(o *Options) { o.MinVowels = 1 }, []string{"abc", "abd", "aby", "acd", "acy", "ady"}},
+		{"abcdy", func(o *Options) { o.MinVowels, o.VowelY = 1, true }, []string{"abc", "abd", "aby", "acd", "acy", "ady", "bcy", "bdy", "cdy"}},
+		{"abcde", func(o *Options) { o.MaxVowels = 1 }, []string{"abc", "abd", "acd", "bcd", "bce", "bde", "cde"}},
+	} {
+		o := DefaultOptions()
+		o.Alphabet = tc.alphabet
+		tc.set(&o)
+		setOpts(t, o)
+		setFlag(t, &genSem, nil)
+		sets, out := make(chan string), make(chan string)
+		go genAllStrings(context.Background(), 3, sets)
+		go vowelLetters(context.Background(), sets, out)
+		if got := collectStrings(out); !slices.Equal(got, tc.want) {
+			t.Errorf("with -min_vowels %d -max_vowels %d -vowel_y=%t, the sets over %s = %q, want %q",
+				o.MinVowels, o.MaxVowels, o.VowelY, tc.alphabet, got, tc.want)
+		}
+	}
+}
+
+func TestRotate(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []string
+	}{
+		{"abcdefg", []string{"abcdefg", "bcdefga", "cdefgab", "defgabc", "efgabcd", "fgabcde", "gabcdef"}},
+		{"a", []string{"a"}},
+	} {
+		in, out := make(chan string, 1), make(chan string)
+		in <- tc.in
+		close(in)
+		go rotate(context.Background(), in, out)
+		got := collectStrings(out)
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("rotate(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+		centers := map[string]bool{}
+		for _, s := range got {
+			centers[centerLetter(s)] = true
+		}
+		if len(centers) != len(tc.in) {
+			t.Errorf("rotate(%q) gave %d distinct centers, want %d", tc.in, len(centers), len(tc.in))
+		}
+	}
+}
+
+func TestBestCenterPuzzleChoosesCenter(t *testing.T) {
+	o := DefaultOptions()
+	o.MinWords = 2
+	setOpts(t, o)
+	setFlag(t, bestCenter, true)
+	setFlag(t, bestCenterBy, *bestCenterBy)
+	// Centered on b there are three answers worth 24 points; on a, two
+	// worth 26.
+	idx := testIndex(t, "abcdefg", "bbbbb", "bbbbc", "aaaaaaaaaaaa")
+	for by, want := range map[string]string{"words": "bacdefg", "points": "abcdefg"} {
+		*bestCenterBy = by
+		p, ok := bestCenterPuzzle(idx, "gfedcba")
+		if !ok || p.letters != want {
+			t.Errorf("with -best_center_by %s, bestCenterPuzzle(gfedcba) = %q, %t; want %q", by, p.letters, ok, want)
+		}
+	}
+}
+
+func TestSkipLettersBestCenterIgnoresCenter(t *testing.T) {
+	idx := sampleIndex(t)
+	setFlag(t, bestCenter, true)
+	p, ok := bestCenterPuzzle(idx, "aelprst")
+	if !ok {
+		t.Fatal("bestCenterPuzzle(aelprst) made no puzzle")
+	}
+	done := map[string]bool{resumeKey(p.letters): true}
+	in, out := make(chan string, 2), make(chan string)
+	in <- "aelprst"
+	in <- "tselrpa"
+	close(in)
+	go skipLetters(context.Background(), in, out, done)
+	for s := range out {
+		t.Errorf("skipLetters passed %q, already written as %q", s, p.letters)
+	}
+}
+
+// craftedDictionary has words that each filter of readWords rejects under
+// the default options.
+var craftedDictionary = []string{"plate", "plates", "lap", "Zebra", "don't", "abcdefgh", "plate"}
+
+func TestValidateOnlyReport(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	setFlag(t, validateOnly, false)
+	setFlag(t, wordsFile, *wordsFile)
+	path := writeTestFile(t, "dict.txt", craftedDictionary...)
+	stdout, _ := captureOutput(t, func() {
+		if err := run([]string{"-validate_only", "-words_file", path}); err != nil {
+			t.Errorf("run -validate_only: %v", err)
+		}
+	})
+	counts := map[string]string{}
+	for _, l := range strings.Split(strings.TrimSpace(stdout), "\n")[1:] {
+		fields := strings.Fields(l)
+		counts[strings.Join(fields[:len(fields)-1], " ")] = fields[len(fields)-1]
+	}
+	want := map[string]string{
+		"read": "7", "too short": "1", "too long": "0", "not in alphabet": "2",
+		"too many letters": "1", "too few letters": "0", "duplicates": "1",
+		"excluded": "0", "kept": "2",
+	}
+	if !maps.Equal(counts, want) {
+		t.Errorf("-validate_only reported %v, want %v; output:\n%s", counts, want, stdout)
+	}
+}
+
+func TestLoadDictionaryStats(t *testing.T) {
+	o := DefaultOptions()
+	o.MaxWordLen = 8
+	// The last word has no newline after it.
+	words := []string{"plate", "lap", "plasterer", "Zebra", "abcdefgh", "plates", "petal"}
+	kept, stats, err := LoadDictionary(strings.NewReader(strings.Join(words, "\n")), o)
+	if err != nil {
+		t.Fatalf("LoadDictionary: %v", err)
+	}
+	want := LoadStats{Read: 7, Kept: 3, TooShort: 1, TooLong: 1, NotInAlphabet: 1, TooManyLetters: 1}
+	if stats != want {
+		t.Errorf("LoadDictionary stats = %+v, want %+v", stats, want)
+	}
+	if !slices.Equal(kept, []string{"plate", "plates", "petal"}) {
+		t.Errorf("LoadDictionary kept %q, want plate, plates and petal", kept)
+	}
+}
+
+// flakyWriter is a puzzleWriter that fails to write the puzzles in fail and
+// pretends to write the rest.
+type flakyWriter struct{ fail map[string]bool }
+
+func (w flakyWriter) write(_ context.Context, p puzzle) (string, error) {
+	if w.fail[p.letters] {
+		return "", syscall.ENOENT
+	}
+	return p.letters + ".txt", nil
+}
+
+func (flakyWriter) close() error { return nil }
+
+func TestWritePuzzlesPartialAndTotalFailure(t *testing.T) {
+	setFlag(t, v, false)
+	write := func(fail ...string) ([]manifestEntry, error) {
+		in := make(chan puzzle, 2)
+		in <- puzzle{letters: "aelprst"}
+		in <- puzzle{letters: "bcdeirt"}
+		close(in)
+		w := flakyWriter{fail: map[string]bool{}}
+		for _, s := range fail {
+			w.fail[s] = true
+		}
+		return writePuzzles(context.Background(), in, w)
+	}
+
+	written, err := write("aelprst")
+	var partial *partialError
+	if !errors.As(err, &partial) || len(written) != 1 {
+		t.Errorf("with one write failing: wrote %d, err %v; want 1 and a *partialError", len(written), err)
+	}
+	written, err = write("aelprst", "bcdeirt")
+	if err == nil || errors.As(err, &partial) || len(written) != 0 {
+		t.Errorf("with every write failing: wrote %d, err %v; want 0 and an error other than *partialError", len(written), err)
+	}
+	if !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("with every write failing: err %v, want it to wrap the first failure", err)
+	}
+}
+
+func TestLoadDictionaryUsesItsAlphabet(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	o := DefaultOptions()
+	o.Alphabet, o.NumLetters, o.MinDistinctLetters = "abcdeéñ", 3, 2
+	kept, stats, err := LoadDictionary(strings.NewReader("abéñe\nñéñéñ\nñññññ\nabbae\n"), o)
+	if err != nil {
+		t.Fatalf("LoadDictionary: %v", err)
+	}
+	if !slices.Equal(kept, []string{"ñéñéñ", "abbae"}) || stats.TooManyLetters != 1 || stats.TooFewLetters != 1 {
+		t.Errorf("with alphabet %q, LoadDictionary kept %q with stats %+v, want ñéñéñ and abbae, one word with too many letters and one with too few", o.Alphabet, kept, stats)
+	}
+}
+
+func TestMainExitCodes(t *testing.T) {
+	if args := os.Getenv("SPELLINGBEE_MAIN_ARGS"); args != "" {
+		os.Args = append([]string{"spellingbee"}, strings.Split(args, "\n")...)
+		main()
+		os.Exit(0)
+	}
+	dict := writeTestFile(t, "dict.txt", spellingbeetest.SampleDictionary()...)
+	sets := writeTestFile(t, "sets.txt", "aelprst", "paelrst")
+	dir := t.TempDir()
+	// A directory in the way of one puzzle's file.
+	if err := os.Mkdir(filepath.Join(dir, "paelrst.txt"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name, outDir string
+		want         int
+	}{
+		{"all written", t.TempDir(), 0},
+		{"some written", dir, 2},
+		{"none written", filepath.Join(dir, "missing"), 1},
+	} {
+		args := []string{"-quiet", "-words_file", dict, "-letters_file", sets, "-out_dir", tc.outDir}
+		cmd := exec.Command(os.Args[0], "-test.run=^TestMainExitCodes$")
+		cmd.Env = append(os.Environ(), "SPELLINGBEE_MAIN_ARGS="+strings.Join(args, "\n"))
+		out, err := cmd.CombinedOutput()
+		code := 0
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			code = exit.ExitCode()
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if code != tc.want {
+			t.Errorf("%s: exit status %d, want %d; output:\n%s", tc.name, code, tc.want, out)
+		}
+	}
+}
+
+func TestShuffleStringsReproducibleAndComplete(t *testing.T) {
+	o := DefaultOptions()
+	o.Alphabet = "abcdefg"
+	setOpts(t, o)
+	shuffled := func(seed int64) []string {
+		sets, out := make(chan string), make(chan string)
+		go genAllStrings(context.Background(), 3, sets)
+		go shuffleStrings(context.Background(), seed, sets, out)
+		return collectStrings(out)
+	}
+	sets := make(chan string)
+	go genAllStrings(context.Background(), 3, sets)
+	all := collectStrings(sets)
+
+	first, again := shuffled(42), shuffled(42)
+	if !slices.Equal(first, again) {
+		t.Errorf("two shuffles with seed 42 differ:\n%q\n%q", first, again)
+	}
+	if slices.Equal(first, all) {
+		t.Errorf("shuffling with seed 42 left the letter sets in order")
+	}
+	sorted := slices.Clone(first)
+	slices.Sort(sorted)
+	if !slices.Equal(sorted, all) {
+		t.Errorf("shuffling with seed 42 gave %q, want every one of %q once", first, all)
+	}
+}
+
+func TestMaxWordLen(t *testing.T) {
+	words := append(spellingbeetest.SampleDictionary(), "pastelplaster")
+	for _, tc := range []struct {
+		max, words, points int
+	}{
+		{0, 13, 70 + 13 + 7},
+		{13, 13, 70 + 13 + 7},
+		{12, 12, 70},
+	} {
+		o := DefaultOptions()
+		o.MaxWordLen = tc.max
+		setOpts(t, o)
+		p, reason := checkPuzzle(testIndex(t, words...), "aelprst")
+		if reason != "" || len(p.words) != tc.words || p.maxPts != tc.points {
+			t.Errorf("with -max_word_len %d: %d answers worth %d (%q), want %d worth %d", tc.max, len(p.words), p.maxPts, reason, tc.words, tc.points)
+		}
+	}
+}
+
+func distinctLetters(s string) int {
+	rs := map[rune]struct{}{}
+	for _, r := range s {
+		rs[r] = struct{}{}
+	}
+	return len(rs)
+}
+
+func TestHasAtMostLettersParity(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	words := []string{"aaaa", "abab", "abcabc", "abcdd", "plaster", "plasters", "abcdefgh", "zyxwvutsrq"}
+	for _, w := range words {
+		for n := 0; n <= 10; n++ {
+			if got, want := hasAtMostLetters(w, defaultAlphabet, n), distinctLetters(w) <= n; got != want {
+				t.Errorf("hasAtMostLetters(%q, %d) = %t, want %t", w, n, got, want)
+			}
+		}
+	}
+	o := DefaultOptions()
+	o.Alphabet = "aábcdeéñ"
+	setOpts(t, o)
+	for _, w := range []string{"ñaña", "ébéñé", "aábcdeéñ"} {
+		for n := 0; n <= 8; n++ {
+			if got, want := hasAtMostLetters(w, o.Alphabet, n), distinctLetters(w) <= n; got != want {
+				t.Errorf("with alphabet %q, hasAtMostLetters(%q, %d) = %t, want %t", o.Alphabet, w, n, got, want)
+			}
+		}
+	}
+}
+
+func BenchmarkDistinctLettersMap(b *testing.B) {
+	words := repoDictionary(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = distinctLetters(words[i%len(words)]) <= 7
+	}
+}
+
+func TestLettersOnlyCount(t *testing.T) {
+	o := DefaultOptions()
+	o.NumLetters = 3
+	setOpts(t, o)
+	setFlag(t, noRotate, false)
+	setFlag(t, output, filepath.Join(t.TempDir(), "sets.txt"))
+	// Nothing should need the dictionary.
+	setFlag(t, wordsFile, filepath.Join(t.TempDir(), "missing.txt"))
+	// C(26, 3) letter sets, each with 3 centers.
+	for _, tc := range []struct {
+		noRotate bool
+		want     int
+	}{{false, 2600 * 3}, {true, 2600}} {
+		*noRotate = tc.noRotate
+		if err := writeLetterSets(context.Background()); err != nil {
+			t.Fatalf("writeLetterSets: %v", err)
+		}
+		b, err := os.ReadFile(*output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+		n := len(lines)
+		slices.Sort(lines)
+		if n != tc.want || len(slices.Compact(lines)) != tc.want {
+			t.Errorf("with -no_rotate=%t, -letters_only wrote %d letter sets, want %d different ones", tc.noRotate, n, tc.want)
+		}
+	}
+}
+
+func TestLowercaseFoldsAccentedCapitals(t *testing.T) {
+	const alphabet = "abcdefghijklmnopqrstuvwxyzéèàç"
+	for w, want := range map[string]string{"École": "école", "ÇA": "ça", "Île": "île"} {
+		if got := foldToAlphabet(w, alphabet); got != want {
+			t.Errorf("foldToAlphabet(%q) = %q, want %q", w, got, want)
+		}
+	}
+	o := DefaultOptions()
+	o.Alphabet, o.Lowercase = alphabet, true
+	words, _, err := LoadDictionary(strings.NewReader("École\nÉlève\n"), o)
+	if err != nil || !slices.Equal(words, []string{"école", "élève"}) {
+		t.Errorf("LoadDictionary with -lowercase = %q, %v; want école and élève", words, err)
+	}
+}
+
+func TestTwoCenters(t *testing.T) {
+	o := DefaultOptions()
+	o.Centers, o.MinWords = 2, 1
+	setOpts(t, o)
+	idx := testIndex(t, spellingbeetest.SampleDictionary()...)
+	p, reason := checkPuzzle(idx, "tpaelrs")
+	if reason != "" {
+		t.Fatalf("checkPuzzle(tpaelrs) rejected it: %s", reason)
+	}
+	want := []string{"pasta", "tapas", "pleat", "plate", "petal", "leapt", "plaster", "plates"}
+	if !slices.Equal(p.words, want) {
+		t.Errorf("with centers t and p, the answers are %q, want %q", p.words, want)
+	}
+	for _, w := range p.words {
+		if !strings.ContainsRune(w, 't') || !strings.ContainsRune(w, 'p') {
+			t.Errorf("answer %q lacks a center letter", w)
+		}
+	}
+
+	if got, want := centerChoices("abc"), []string{"abc", "acb", "bca"}; !slices.Equal(got, want) {
+		t.Errorf("with two centers, centerChoices(abc) = %q, want %q", got, want)
+	}
+}
+
+func TestMinDistinctInWord(t *testing.T) {
+	words := append(spellingbeetest.SampleDictionary(), "aaaaa")
+	setOpts(t, DefaultOptions())
+	if p, _ := checkPuzzle(testIndex(t, words...), "aelprst"); !slices.Contains(p.words, "aaaaa") {
+		t.Fatalf("by default, the answers %q lack aaaaa", p.words)
+	}
+
+	o := DefaultOptions()
+	o.MinDistinctLetters = 2
+	setOpts(t, o)
+	p, reason := checkPuzzle(testIndex(t, words...), "aelprst")
+	if reason != "" || slices.Contains(p.words, "aaaaa") || len(p.words) != 12 {
+		t.Errorf("with -min_distinct_in_word 2, the answers are %q (rejected: %q), want the 12 without aaaaa", p.words, reason)
+	}
+	if err := CheckWord("aaaaa", "aelprst"); err == nil {
+		t.Error("with -min_distinct_in_word 2, CheckWord(aaaaa) accepted it")
+	}
+}
+
+func TestCheckPuzzlePangramGate(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	words := []string{"apple", "pasta", "tapas", "areal", "alert", "pleat", "plate", "petal", "leapt", "sepal"}
+
+	// Ten answers is enough, but none uses all seven letters.
+	p, reason := checkPuzzle(testIndex(t, words...), "aelprst")
+	if reason != "no pangram" || len(p.words) != 10 {
+		t.Errorf("without a pangram, checkPuzzle(aelprst) gave %d answers and reason %q, want 10 and \"no pangram\"", len(p.words), reason)
+	}
+
+	// 5 points for each five-letter answer, and 7 plus a 7 point bonus for
+	// the pangram.
+	p, reason = checkPuzzle(testIndex(t, append(words, "plaster")...), "aelprst")
+	if reason != "" {
+		t.Fatalf("with plaster, checkPuzzle(aelprst) rejected it: %s", reason)
+	}
+	if want := 10*5 + 7 + 7; len(p.words) != 11 || p.maxPts != want {
+		t.Errorf("with plaster, checkPuzzle(aelprst) gave %d answers worth %d, want 11 worth %d", len(p.words), p.maxPts, want)
+	}
+
+	// Nine answers and a pangram is still too few.
+	if _, reason := checkPuzzle(testIndex(t, slices.Concat(words[:8], []string{"plaster"})...), "aelprst"); reason != "too few words" {
+		t.Errorf("with nine answers, checkPuzzle(aelprst) gave reason %q, want \"too few words\"", reason)
+	}
+}
+
+func TestGenParallelBoundsGoroutines(t *testing.T) {
+	o := DefaultOptions()
+	o.Alphabet = "abcdefghijkl"
+	setOpts(t, o)
+	const limit = 2
+	setFlag(t, &genSem, make(chan struct{}, limit))
+	base := runtime.NumGoroutine()
+	out := make(chan string)
+	go genAllStrings(context.Background(), 5, out)
+	n, most := 0, 0
+	for range out {
+		n++
+		most = max(most, runtime.NumGoroutine()-base)
+	}
+	// The goroutine running the outermost genAllStrings, and limit more.
+	if most > limit+1 {
+		t.Errorf("with -gen_parallel %d, up to %d goroutines generated letter sets, want at most %d", limit, most, limit+1)
+	}
+	if n != 792 {
+		t.Errorf("genAllStrings(5) over 12 letters sent %d sets, want C(12, 5) = 792", n)
+	}
+}
+
+func TestLowercaseDeduplicates(t *testing.T) {
+	o := DefaultOptions()
+	o.Lowercase = true
+	words, stats, err := LoadDictionary(strings.NewReader("Polish\npolish\nPOLISH\nplate\n"), o)
+	if err != nil {
+		t.Fatalf("LoadDictionary: %v", err)
+	}
+	if !slices.Equal(words, []string{"polish", "plate"}) || stats.Duplicates != 2 {
+		t.Errorf("with -lowercase, LoadDictionary kept %q with %d duplicates, want polish and plate with 2", words, stats.Duplicates)
+	}
+
+	o.Lowercase = false
+	if words, _, _ := LoadDictionary(strings.NewReader("Polish\npolish\n"), o); !slices.Equal(words, []string{"polish"}) {
+		t.Errorf("without -lowercase, LoadDictionary kept %q, want only polish", words)
+	}
+}
+
+func TestMinAvgPoints(t *testing.T) {
+	idx := sampleIndex(t)
+	o := DefaultOptions()
+	// aelprst's 12 answers average 70/12 points, paelrst's 10 average 6.
+	o.MinAvgPoints = 6
+	setOpts(t, o)
+	if got := puzzleLetters(matchAll(idx, "aelprst", "paelrst")); !slices.Equal(got, []string{"paelrst"}) {
+		t.Errorf("with -min_avg_points 6, made %q, want only paelrst", got)
+	}
+	o.MinAvgPoints = 5.5
+	setOpts(t, o)
+	if got := puzzleLetters(matchAll(idx, "aelprst", "paelrst")); len(got) != 2 {
+		t.Errorf("with -min_avg_points 5.5, made %q, want both", got)
+	}
+}
+
+func TestSamplePuzzleIsConsistent(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	sp := spellingbeetest.SamplePuzzle()
+	pts := 0
+	for _, w := range sp.Words {
+		if err := CheckWord(w, sp.Letters); err != nil {
+			t.Errorf("SamplePuzzle answer: %v", err)
+		}
+		pts += ScoreWord(w, sp.Letters)
+	}
+	if pts != sp.MaxPoints {
+		t.Errorf("SamplePuzzle's answers score %d, but its MaxPoints is %d", pts, sp.MaxPoints)
+	}
+
+	// It is what spelling-bee makes from SampleDictionary.
+	ps := matchAll(sampleIndex(t), sp.Letters)
+	if len(ps) != 1 {
+		t.Fatalf("made %d puzzles from %s, want 1", len(ps), sp.Letters)
+	}
+	r := newPuzzleRecord(ps[0])
+	got := spellingbeetest.Puzzle{
+		Letters: r.Letters, Center: r.Center, Outer: r.Outer, Words: r.Words,
+		MaxPoints: r.MaxPoints, LongestWordLen: r.LongestWordLen, NearPangrams: r.NearPangrams,
+	}
+	if !reflect.DeepEqual(got, sp) {
+		t.Errorf("from SampleDictionary, made %+v, want SamplePuzzle %+v", got, sp)
+	}
+}
+
+func TestMinStartLetters(t *testing.T) {
+	o := DefaultOptions()
+	o.MinStartLetters = 2
+	setOpts(t, o)
+	same := puzzle{letters: "aelprst", words: []string{"apple", "areal", "alert"}, maxPts: 15}
+	if reason := finalCheck(same); reason != "too few start letters" {
+		t.Errorf("with every answer starting with a, finalCheck gave reason %q, want \"too few start letters\"", reason)
+	}
+	varied := puzzle{letters: "aelprst", words: []string{"apple", "areal", "plate"}, maxPts: 15}
+	if reason := finalCheck(varied); reason != "" {
+		t.Errorf("with answers starting with a and p, finalCheck rejected it: %s", reason)
+	}
+
+	// The sample puzzle's answers start with a, l, p, s and t.
+	idx := sampleIndex(t)
+	for n, want := range map[int]int{5: 1, 6: 0} {
+		o.MinStartLetters = n
+		setOpts(t, o)
+		if got := len(matchAll(idx, "aelprst")); got != want {
+			t.Errorf("with -min_start_letters %d, made %d puzzles from aelprst, want %d", n, got, want)
+		}
+	}
+}
+
+func TestFirstPuzzlesStopsUpstream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// An endless stream of puzzles, as from generating every letter set.
+	in := make(chan puzzle)
+	go func() {
+		defer close(in)
+		for {
+			select {
+			case in <- samplePuzzle():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	out := firstPuzzles(in, 3, cancel)
+	n := 0
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case _, ok := <-out:
+			if ok {
+				n++
+				continue
+			}
+			if n != 3 {
+				t.Errorf("firstPuzzles(3) passed on %d puzzles", n)
+			}
+			return
+		case <-timeout:
+			t.Fatalf("firstPuzzles(3) still running 10s after passing on %d puzzles", n)
+		}
+	}
+}
+
+func TestFirstN(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	setFlag(t, quiet, false)
+	setFlag(t, v, true)
+	setFlag(t, wordsFile, *wordsFile)
+	setFlag(t, letters, *letters)
+	setFlag(t, outDir, *outDir)
+	setFlag(t, firstN, *firstN)
+	dict := writeTestFile(t, "dict.txt", spellingbeetest.SampleDictionary()...)
+	// Of the 56 letter sets, the rotations of aelprst centered on a, e, l, p
+	// and t make puzzles.
+	dir := t.TempDir()
+	captureOutput(t, func() {
+		if err := run([]string{"-quiet", "-words_file", dict, "-alphabet", "aelprstx", "-first_n", "2", "-out_dir", dir}); err != nil {
+			t.Errorf("run: %v", err)
+		}
+	})
+	if files, _ := os.ReadDir(dir); len(files) != 2 {
+		t.Errorf("with -first_n 2, wrote %d files, want 2", len(files))
+	}
+}
+
+// syntheticDictionary returns n random words of 5 to 10 letters drawn from
+// the first 14 letters of the alphabet, the same for every call.
+func syntheticDictionary(n int) string {
+	r := rand.New(rand.NewPCG(1, 2))
+	var b strings.Builder
+	for range n {
+		for range 5 + r.IntN(6) {
+			b.WriteByte(byte('a' + r.IntN(14)))
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// BenchmarkLoadDictionary loads a dictionary of 300,000 synthetic words,
+// indexes it and builds 100 puzzles from it, to guard memory use. The
+// baseline is about 210 MB in 2.2 million allocations per run, about 70% of
+// both from containsOnly's map per word.
+func BenchmarkLoadDictionary(b *testing.B) {
+	setOpts(b, DefaultOptions())
+	dict := syntheticDictionary(300_000)
+	var letters []string
+	eachString([]rune("abcdefghijklmn"), 7, func(s string) bool {
+		letters = append(letters, s)
+		return len(letters) < 100
+	})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		words, _, err := LoadDictionary(strings.NewReader(dict), opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+		idx := newWordIndex(words)
+		for _, s := range letters {
+			checkPuzzle(idx, s)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// manifestEntry describes one written puzzle file.
+type manifestEntry struct {
+	Letters string `json:"letters"`
+	File    string `json:"file"`
+	Words   int    `json:"words"`
+	Points  int    `json:"points"`
+}
+
+// A manifest collects the entries of written puzzles. It is safe for
+// concurrent use, so puzzles can be written from several goroutines.
+type manifest struct {
+	mu      sync.Mutex
+	entries []manifestEntry
+}
+
+// add records that p was written to the file fn.
+func (m *manifest) add(p puzzle, fn string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, manifestEntry{
+		Letters: p.letters,
+		File:    fn,
+		Words:   len(p.words),
+		Points:  p.maxPts,
+	})
+}
+
+// list returns the entries added so far.
+func (m *manifest) list() []manifestEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]manifestEntry{}, m.entries...)
+}
+
+// sortManifest orders entries by "words" or "points", highest first. Ties are
+// broken by letters so the order is stable across runs.
+func sortManifest(entries []manifestEntry, by string) error {
+	var key func(e manifestEntry) int
+	switch by {
+	case "words":
+		key = func(e manifestEntry) int { return e.Words }
+	case "points":
+		key = func(e manifestEntry) int { return e.Points }
+	default:
+		return fmt.Errorf("unknown manifest sort %q, want words or points", by)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if ki, kj := key(entries[i]), key(entries[j]); ki != kj {
+			return ki > kj
+		}
+		return entries[i].Letters < entries[j].Letters
+	})
+	return nil
+}
+
+// readManifest reads a manifest written by writeManifest.
+func readManifest(path string) ([]manifestEntry, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []manifestEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// writeManifest sorts entries and writes them to path as JSON.
+func writeManifest(path string, entries []manifestEntry, by string) error {
+	if err := sortManifest(entries, by); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// rebuildManifest returns manifest entries for the txt puzzles in dir,
+// including sharded ones, read back from the files. Files that aren't
+// puzzles are skipped with a warning.
+func rebuildManifest(dir string) ([]manifestEntry, error) {
+	entries := []manifestEntry{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".txt" {
+			return nil
+		}
+		letters := strings.TrimSuffix(d.Name(), ".txt")
+		if err := validateLetters(letters, opts.NumLetters); err != nil {
+			slog.Warn("Skipping file", "file", path, "err", err)
+			return nil
+		}
+		words, last, _, err := readTxtPuzzle(path, letters)
+		if err != nil {
+			return err
+		}
+		pts, err := strconv.Atoi(last)
+		if words == nil || err != nil {
+			slog.Warn("Skipping file", "file", path, "err", "no point total")
+			return nil
+		}
+		fn, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, manifestEntry{
+			Letters: letters,
+			File:    fn,
+			Words:   len(words),
+			Points:  pts,
+		})
+		return nil
+	})
+	return entries, err
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/nickgraffis/spelling-bee/spellingbeetest"
+)
+
+func TestWriteManifestSortOrder(t *testing.T) {
+	entries := []manifestEntry{
+		{Letters: "aelprst", Words: 12, Points: 70},
+		{Letters: "bcdeirt", Words: 20, Points: 60},
+		{Letters: "acdeirt", Words: 12, Points: 80},
+	}
+	for _, tc := range []struct {
+		by   string
+		want []string
+	}{
+		{"words", []string{"bcdeirt", "acdeirt", "aelprst"}},
+		{"points", []string{"acdeirt", "aelprst", "bcdeirt"}},
+	} {
+		path := filepath.Join(t.TempDir(), "manifest.json")
+		if err := writeManifest(path, append([]manifestEntry{}, entries...), tc.by); err != nil {
+			t.Fatalf("writeManifest by %s: %v", tc.by, err)
+		}
+		read, err := readManifest(path)
+		if err != nil {
+			t.Fatalf("readManifest: %v", err)
+		}
+		got := []string{}
+		for _, e := range read {
+			got = append(got, e.Letters)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("manifest sorted by %s = %q, want %q", tc.by, got, tc.want)
+		}
+	}
+	if err := sortManifest(entries, "letters"); err == nil {
+		t.Error("sortManifest by letters succeeded, want an error")
+	}
+}
+
+func TestResumeSkipsManifestSets(t *testing.T) {
+	setFlag(t, v, false)
+	setFlag(t, wordsFile, *wordsFile)
+	setFlag(t, lettersFile, *lettersFile)
+	setFlag(t, resume, *resume)
+	dict := writeTestFile(t, "dict.txt", spellingbeetest.SampleDictionary()...)
+	sets := writeTestFile(t, "sets.txt", "aelprst", "paelrst")
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	if err := writeManifest(path, []manifestEntry{{Letters: "aelprst", File: "aelprst.txt"}}, "words"); err != nil {
+		t.Fatal(err)
+	}
+	dir := chdirPuzzles(t)
+	if err := run([]string{"-words_file", dict, "-letters_file", sets, "-resume", path}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, f := range files {
+		got = append(got, f.Name())
+	}
+	if !reflect.DeepEqual(got, []string{"paelrst.txt"}) {
+		t.Errorf("resuming from a manifest with aelprst wrote %q, want only paelrst.txt", got)
+	}
+}
+
+func TestRebuildManifest(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	setFlag(t, shardOutputByCenter, true)
+	ps := []puzzle{samplePuzzle(), {letters: "paelrst", words: []string{"plaster", "plates"}, maxPts: 20}}
+	dir := writeFormat(t, "txt", ps...)
+	for name, content := range map[string]string{"notes.txt": "not a puzzle\n", "rstlnea.txt": "alert\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := rebuildManifest(dir)
+	if err != nil {
+		t.Fatalf("rebuildManifest: %v", err)
+	}
+	if err := sortManifest(got, "words"); err != nil {
+		t.Fatal(err)
+	}
+	want := []manifestEntry{
+		{Letters: "aelprst", File: filepath.Join("a", "aelprst.txt"), Words: 12, Points: 70},
+		{Letters: "paelrst", File: filepath.Join("p", "paelrst.txt"), Words: 2, Points: 20},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rebuildManifest = %+v, want %+v", got, want)
+	}
+}
+
+func TestManifestConcurrentAdds(t *testing.T) {
+	const writers, each = 8, 250
+	var m manifest
+	var wg sync.WaitGroup
+	for range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, p := range manyPuzzles(each) {
+				m.add(p, p.letters+".txt")
+			}
+		}()
+	}
+	wg.Wait()
+	if got := len(m.list()); got != writers*each {
+		t.Errorf("after %d concurrent adds, the manifest has %d entries", writers*each, got)
+	}
+
+	setOpts(t, DefaultOptions())
+	setFlag(t, v, false)
+	setFlag(t, outDir, t.TempDir())
+	ps := manyPuzzles(500)
+	in := make(chan puzzle, len(ps))
+	for _, p := range ps {
+		in <- p
+	}
+	close(in)
+	entries, err := writePuzzles(context.Background(), in, newTxtWriter(*outDir, false, false, false, 16))
+	if err != nil {
+		t.Fatalf("writePuzzles: %v", err)
+	}
+	if len(entries) != len(ps) {
+		t.Errorf("writePuzzles wrote %d puzzles, but the manifest has %d entries", len(ps), len(entries))
+	}
+}
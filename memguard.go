@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// A memGuard pauses the flow of letter sets to the matching goroutines while
+// the heap is over a soft limit, so puzzles already in flight are written
+// and freed before more are built.
+type memGuard struct {
+	limit uint64
+	// heap returns the heap size and gc forces a collection; they are
+	// replaced in tests.
+	heap func() uint64
+	gc   func()
+
+	// Used only by watch's goroutine.
+	last   uint64 // heap size when throttling last started or continued
+	gaveUp bool   // throttling didn't help and is off until under the limit
+	warned bool
+
+	mu     sync.Mutex
+	over   bool
+	resume chan struct{} // closed when over becomes false
+}
+
+func newMemGuard(limit uint64) *memGuard {
+	heap := func() uint64 {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		return ms.HeapAlloc
+	}
+	return &memGuard{limit: limit, heap: heap, gc: runtime.GC, resume: make(chan struct{})}
+}
+
+// watch calls poll every so often until ctx is done.
+func (g *memGuard) watch(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			g.set(false)
+			return
+		case <-t.C:
+		}
+		g.poll()
+	}
+}
+
+// poll checks the heap size, throttling while it is over the limit. While
+// throttled it forces a collection each poll. If that doesn't shrink the
+// heap, the memory isn't held by puzzles in flight and pausing can't help,
+// so it gives up: throttling stays off until the heap is under the limit
+// again.
+func (g *memGuard) poll() {
+	heap := g.heap()
+	if heap <= g.limit {
+		g.gaveUp = false
+		g.set(false)
+		return
+	}
+	if g.gaveUp {
+		return
+	}
+	if g.paused() {
+		g.gc()
+		heap = g.heap()
+		if heap >= g.last {
+			if !g.warned {
+				slog.Warn("Heap didn't shrink while throttled; -max_memory may be too low", "heap_mb", heap>>20)
+				g.warned = true
+			}
+			g.gaveUp = true
+			g.set(false)
+			return
+		}
+	}
+	g.last = heap
+	g.set(true)
+}
+
+func (g *memGuard) paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.over
+}
+
+func (g *memGuard) set(over bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if over == g.over {
+		return
+	}
+	g.over = over
+	if over {
+		throttled.Add(1)
+		slog.Debug("Throttling", "limit_mb", g.limit>>20)
+		g.resume = make(chan struct{})
+	} else {
+		slog.Debug("Resuming")
+		close(g.resume)
+	}
+}
+
+// wait blocks while the guard is over its limit. It reports false if ctx is
+// done first.
+func (g *memGuard) wait(ctx context.Context) bool {
+	g.mu.Lock()
+	over, resume := g.over, g.resume
+	g.mu.Unlock()
+	if !over {
+		return true
+	}
+	select {
+	case <-resume:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// throttle sends each letter set from in to out, waiting first while g is
+// over its limit, then closes out.
+func throttle(ctx context.Context, g *memGuard, in <-chan string, out chan<- string) {
+	defer close(out)
+	for s := range in {
+		if !g.wait(ctx) || !send(ctx, out, s) {
+			return
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemGuardGivesUpUntilUnderLimit(t *testing.T) {
+	heap := uint64(0)
+	g := newMemGuard(100)
+	g.heap = func() uint64 { return heap }
+	g.gc = func() {}
+
+	heap = 150
+	g.poll()
+	if !g.paused() {
+		t.Fatal("not throttled over the limit")
+	}
+	// The collection doesn't shrink the heap, so throttling can't help.
+	for i := 0; i < 4; i++ {
+		g.poll()
+		if g.paused() {
+			t.Fatalf("poll %d after giving up: throttled again", i+1)
+		}
+	}
+
+	heap = 50
+	g.poll()
+	heap = 150
+	g.poll()
+	if !g.paused() {
+		t.Error("not throttled over the limit again after dropping under it")
+	}
+}
+
+func TestMemGuardKeepsThrottlingWhileHeapShrinks(t *testing.T) {
+	heap := uint64(200)
+	g := newMemGuard(100)
+	g.heap = func() uint64 { return heap }
+	g.gc = func() { heap -= 10 }
+	for i := 0; i < 5; i++ {
+		g.poll()
+		if !g.paused() {
+			t.Fatalf("poll %d: not throttled while the heap shrinks over the limit", i+1)
+		}
+	}
+}
+
+func TestThrottleEngagesAndCompletes(t *testing.T) {
+	heap := uint64(200)
+	g := newMemGuard(100)
+	g.heap = func() uint64 { return heap }
+	g.gc = func() { heap -= 30 }
+	before := throttled.Value()
+	g.poll()
+	if got := throttled.Value() - before; got != 1 {
+		t.Fatalf("throttled went up by %d over the limit, want 1", got)
+	}
+
+	in, out := make(chan string, 3), make(chan string)
+	in <- "aelprst"
+	in <- "bcdeirt"
+	in <- "cdeinor"
+	close(in)
+	go throttle(context.Background(), g, in, out)
+	select {
+	case s := <-out:
+		t.Fatalf("throttle passed %q while over the limit", s)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Each poll collects 30 more, until the heap is under the limit.
+	for g.paused() {
+		g.poll()
+	}
+	got := []string{}
+	for s := range out {
+		got = append(got, s)
+	}
+	if len(got) != 3 {
+		t.Errorf("throttle passed %q once under the limit, want all 3 letter sets", got)
+	}
+}
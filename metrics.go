@@ -0,0 +1,91 @@
+package main
+
+import (
+	"expvar"
+	"log/slog"
+)
+
+// Runtime metrics, published by expvar and served at /debug/vars in serve
+// mode. The -parallel matching goroutines and the server's handlers update
+// them concurrently; expvar.Int is atomic, so counters added here must be
+// expvar.Ints too, not plain ints.
+var (
+	// puzzlesGenerated counts letter sets that made a valid puzzle.
+	puzzlesGenerated = expvar.NewInt("puzzles_generated")
+	// requestsServed counts requests answered by the puzzle API.
+	requestsServed = expvar.NewInt("requests_served")
+	// cacheHits counts puzzles the server found in its cache.
+	cacheHits = expvar.NewInt("cache_hits")
+
+	// Letter sets rejected, by reason.
+	rejectedFewWords        = expvar.NewInt("rejected_few_words")
+	rejectedNoPangram       = expvar.NewInt("rejected_no_pangram")
+	rejectedNoCommonPangram = expvar.NewInt("rejected_no_common_pangram")
+	rejectedManyPangrams    = expvar.NewInt("rejected_many_pangrams")
+	rejectedNoNearPangram   = expvar.NewInt("rejected_no_near_pangram")
+	rejectedFewEasyWords    = expvar.NewInt("rejected_few_easy_words")
+	rejectedPoints          = expvar.NewInt("rejected_points")
+	rejectedLowAverage      = expvar.NewInt("rejected_low_average")
+	rejectedStartLetters    = expvar.NewInt("rejected_start_letters")
+
+	// throttled counts the times -max_memory paused generation.
+	throttled = expvar.NewInt("throttled")
+)
+
+// countRejection counts a letter set rejected for reason, as returned by
+// checkPuzzle.
+func countRejection(reason string) {
+	switch reason {
+	case "too few words":
+		rejectedFewWords.Add(1)
+	case "no pangram":
+		rejectedNoPangram.Add(1)
+	case "no common pangram":
+		rejectedNoCommonPangram.Add(1)
+	case "too many pangrams":
+		rejectedManyPangrams.Add(1)
+	case "no near pangram":
+		rejectedNoNearPangram.Add(1)
+	case "too few easy words":
+		rejectedFewEasyWords.Add(1)
+	case "points out of range":
+		rejectedPoints.Add(1)
+	case "average points too low":
+		rejectedLowAverage.Add(1)
+	case "too few start letters":
+		rejectedStartLetters.Add(1)
+	}
+}
+
+// rejectLetters counts the letter set s as rejected for reason and logs it
+// at debug level. With -verbose_rejections the log also gives the number of
+// answers and pangrams of p, the puzzle s made; these are only counted then,
+// so normal runs don't pay for them.
+func rejectLetters(s string, p puzzle, reason string) {
+	countRejection(reason)
+	if !*verboseRejections {
+		slog.Debug("Rejected letters", "letters", s, "reason", reason)
+		return
+	}
+	pangrams := 0
+	for _, w := range p.words {
+		if isPangram(w, s) {
+			pangrams++
+		}
+	}
+	slog.Debug("Rejected letters", "letters", s, "reason", reason, "words", len(p.words), "pangrams", pangrams)
+}
+
+// logRejections logs how many letter sets were rejected for each reason.
+func logRejections() {
+	slog.Info("Rejected letter sets",
+		"few_words", rejectedFewWords.Value(),
+		"no_pangram", rejectedNoPangram.Value(),
+		"no_common_pangram", rejectedNoCommonPangram.Value(),
+		"many_pangrams", rejectedManyPangrams.Value(),
+		"no_near_pangram", rejectedNoNearPangram.Value(),
+		"few_easy_words", rejectedFewEasyWords.Value(),
+		"points", rejectedPoints.Value(),
+		"low_average", rejectedLowAverage.Value(),
+		"start_letters", rejectedStartLetters.Value())
+}
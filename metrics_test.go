@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nickgraffis/spelling-bee/spellingbeetest"
+)
+
+// expvarInt returns the value of the expvar.Int published as name.
+func expvarInt(t *testing.T, name string) int64 {
+	t.Helper()
+	v, ok := expvar.Get(name).(*expvar.Int)
+	if !ok {
+		t.Fatalf("no expvar.Int %q is published", name)
+	}
+	return v.Value()
+}
+
+func TestMetricsAfterGeneratingPuzzle(t *testing.T) {
+	idx := sampleIndex(t)
+	generated, served := expvarInt(t, "puzzles_generated"), expvarInt(t, "requests_served")
+	if _, ok := makePuzzle(idx, "aelprst"); !ok {
+		t.Fatal("makePuzzle(aelprst) made no puzzle")
+	}
+	if got := expvarInt(t, "puzzles_generated") - generated; got != 1 {
+		t.Errorf("puzzles_generated went up by %d after one puzzle, want 1", got)
+	}
+
+	s := &server{idx: idx, cache: newPuzzleCache(0)}
+	w := get(s, "/debug/vars", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /debug/vars: status %d", w.Code)
+	}
+	var vars map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("GET /debug/vars: %v", err)
+	}
+	if got, want := vars["puzzles_generated"], float64(generated+1); got != want {
+		t.Errorf("/debug/vars has puzzles_generated %v, want %v", got, want)
+	}
+	if got, want := vars["requests_served"], float64(served+1); got != want {
+		t.Errorf("/debug/vars has requests_served %v, want %v", got, want)
+	}
+}
+
+func TestBestCenterPuzzleCountsOnce(t *testing.T) {
+	idx := sampleIndex(t)
+	setFlag(t, bestCenter, true)
+	generated, fewWords := puzzlesGenerated.Value(), rejectedFewWords.Value()
+	if _, ok := bestCenterPuzzle(idx, "aelprst"); !ok {
+		t.Fatal("bestCenterPuzzle(aelprst) made no puzzle")
+	}
+	if _, ok := bestCenterPuzzle(idx, "bcdfghj"); ok {
+		t.Fatal("bestCenterPuzzle(bcdfghj) made a puzzle")
+	}
+	if got := puzzlesGenerated.Value() - generated; got != 1 {
+		t.Errorf("puzzles_generated went up by %d, want 1", got)
+	}
+	if got := rejectedFewWords.Value() - fewWords; got != 1 {
+		t.Errorf("rejected_few_words went up by %d, want 1", got)
+	}
+}
+
+func TestRejectionCounters(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	// The sample dictionary without its pangram, plaster.
+	idx := testIndex(t, slices.DeleteFunc(spellingbeetest.SampleDictionary(), func(w string) bool { return w == "plaster" })...)
+	generated, fewWords, noPangram := puzzlesGenerated.Value(), rejectedFewWords.Value(), rejectedNoPangram.Value()
+	if ps := matchAll(idx, "aelprst", "raelpst", "paelrst", "xyzabcd"); len(ps) != 0 {
+		t.Errorf("matchWords made %d puzzles without a pangram, want 0", len(ps))
+	}
+	if got := puzzlesGenerated.Value() - generated; got != 0 {
+		t.Errorf("puzzles_generated went up by %d, want 0", got)
+	}
+	// Only aelprst has enough answers.
+	if got := rejectedFewWords.Value() - fewWords; got != 3 {
+		t.Errorf("rejected_few_words went up by %d, want 3", got)
+	}
+	if got := rejectedNoPangram.Value() - noPangram; got != 1 {
+		t.Errorf("rejected_no_pangram went up by %d, want 1", got)
+	}
+}
+
+func TestVerboseRejectionsLog(t *testing.T) {
+	idx := sampleIndex(t)
+	old := slog.SetLogLoggerLevel(slog.LevelDebug)
+	t.Cleanup(func() { slog.SetLogLoggerLevel(old) })
+	for verbose, want := range map[bool]string{
+		false: `Rejected letters letters=raelpst reason="too few words"` + "\n",
+		true:  `Rejected letters letters=raelpst reason="too few words" words=4 pangrams=1` + "\n",
+	} {
+		setFlag(t, verboseRejections, verbose)
+		_, logged := captureOutput(t, func() {
+			if _, ok := makePuzzle(idx, "raelpst"); ok {
+				t.Error("makePuzzle(raelpst) made a puzzle of its 4 answers")
+			}
+		})
+		if !strings.HasSuffix(logged, want) {
+			t.Errorf("with -verbose_rejections=%v, logged %q, want a line ending %q", verbose, logged, want)
+		}
+	}
+
+	// Rejections aren't logged at the default level.
+	setFlag(t, verboseRejections, false)
+	slog.SetLogLoggerLevel(slog.LevelInfo)
+	if _, logged := captureOutput(t, func() { makePuzzle(idx, "raelpst") }); logged != "" {
+		t.Errorf("at info level, a rejection logged %q, want nothing", logged)
+	}
+}
+
+// rejectedTotal returns the sum of the rejection counters.
+func rejectedTotal() int64 {
+	var n int64
+	expvar.Do(func(kv expvar.KeyValue) {
+		if v, ok := kv.Value.(*expvar.Int); ok && strings.HasPrefix(kv.Key, "rejected_") {
+			n += v.Value()
+		}
+	})
+	return n
+}
+
+// TestCountersWithParallelMatching reads the counters while several matching
+// goroutines update them; run it with -race.
+func TestCountersWithParallelMatching(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	setFlag(t, wordsFile, writeTestFile(t, "dict.txt", spellingbeetest.SampleDictionary()...))
+	setFlag(t, letters, "")
+	sets := centerChoices("aelprst")
+	setFlag(t, lettersFile, writeTestFile(t, "sets.txt", sets...))
+	setFlag(t, parallel, 4)
+	generated, rejected := puzzlesGenerated.Value(), rejectedTotal()
+
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		tick := time.NewTicker(time.Millisecond)
+		defer tick.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-tick.C:
+				puzzlesGenerated.Value()
+				rejectedTotal()
+			}
+		}
+	}()
+	var got []string
+	err := generate(context.Background(), nil, func(in <-chan puzzle) {
+		for p := range in {
+			got = append(got, p.letters)
+		}
+	})
+	close(stop)
+	<-stopped
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	// Each letter set is counted once: the five centers a, e, l, p and t
+	// make puzzles, and r and s too few answers.
+	g, r := puzzlesGenerated.Value()-generated, rejectedTotal()-rejected
+	if len(got) != 5 || g != 5 || r != 2 {
+		t.Errorf("made %d puzzles; counted %d generated and %d rejected, want 5, 5 and 2", len(got), g, r)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// writeMinDictionary writes the answers of the puzzles in -letters and
+// -letters_file, each once and in dictionary order, to -output or stdout:
+// the smallest dictionary that makes the same puzzles. Letter sets that
+// don't make a valid puzzle are warned about, but their answers are still
+// written.
+func writeMinDictionary() error {
+	sets, err := readLetterSets()
+	if err != nil {
+		return err
+	}
+	if len(sets) == 0 {
+		return fmt.Errorf("-min_dictionary needs -letters or -letters_file")
+	}
+	idx, err := loadIndex(*wordsFile)
+	if err != nil {
+		return err
+	}
+	answers := map[string]bool{}
+	for _, s := range sets {
+		p, reason := checkPuzzle(idx, s)
+		if reason != "" {
+			slog.Warn("Letter set doesn't make a puzzle", "letters", s, "reason", reason)
+		}
+		for _, w := range p.words {
+			answers[w] = true
+		}
+	}
+
+	var f *os.File
+	b := bufio.NewWriter(os.Stdout)
+	if *output != "" {
+		if f, err = os.Create(*output); err != nil {
+			return err
+		}
+		defer f.Close()
+		b = bufio.NewWriter(f)
+	}
+	for _, w := range idx.words {
+		if answers[w] {
+			fmt.Fprintln(b, w)
+		}
+	}
+	if err := b.Flush(); err != nil {
+		return err
+	}
+	slog.Info("Wrote dictionary", "puzzles", len(sets), "words", len(answers))
+	if f != nil {
+		return f.Close()
+	}
+	return nil
+}
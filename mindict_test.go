@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nickgraffis/spelling-bee/spellingbeetest"
+)
+
+func TestWriteMinDictionary(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	setFlag(t, wordsFile, writeTestFile(t, "dict.txt", spellingbeetest.SampleDictionary()...))
+	setFlag(t, letters, "")
+	// eaplrst has every answer of aelprst with an e, and trees.
+	setFlag(t, lettersFile, writeTestFile(t, "sets.txt", "aelprst", "eaplrst"))
+	setFlag(t, output, filepath.Join(t.TempDir(), "min.txt"))
+	if err := writeMinDictionary(); err != nil {
+		t.Fatalf("writeMinDictionary: %v", err)
+	}
+	got, err := os.ReadFile(*output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append(spellingbeetest.SamplePuzzle().Words, "trees")
+	if w := strings.Join(want, "\n") + "\n"; string(got) != w {
+		t.Errorf("the dictionary for aelprst and eaplrst is\n%s\nwant\n%s", got, w)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// normForms are the Unicode normalization forms -normalize accepts.
+var normForms = map[string]norm.Form{
+	"NFC": norm.NFC,
+	"NFD": norm.NFD,
+}
+
+// validateNormalize reports whether name is a form -normalize accepts, or
+// empty for none.
+func validateNormalize(name string) error {
+	if _, ok := normForms[name]; !ok && name != "" {
+		return fmt.Errorf("Normalize is %q, want NFC, NFD or empty", name)
+	}
+	return nil
+}
+
+// normalize returns s in the Unicode normalization form o.Normalize, so an
+// accented letter matches whether it was written composed, like "é", or as
+// a plain letter and a combining accent. With NFD accented letters become
+// two letters, the plain one and the accent, and the alphabet must list
+// both. With no form s is returned as is.
+func (o Options) normalize(s string) string {
+	if f, ok := normForms[o.Normalize]; ok {
+		return f.String(s)
+	}
+	return s
+}
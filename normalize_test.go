@@ -0,0 +1,36 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeUnifiesComposedAndDecomposed(t *testing.T) {
+	const composed, decomposed = "caf\u00e9s", "cafe\u0301s"
+	dict := composed + "\n" + decomposed + "\n"
+	for _, tc := range []struct {
+		form, alphabet string
+		want           []string
+	}{
+		{"", defaultAlphabet + "\u00e9", []string{composed}},
+		{"NFC", defaultAlphabet + "\u00e9", []string{composed}},
+		// With NFD the accent is a letter of its own, and é two letters.
+		{"NFD", defaultAlphabet + "\u0301", []string{decomposed}},
+		{"NFD", defaultAlphabet + "\u00e9\u0301", []string{decomposed}},
+	} {
+		o := DefaultOptions()
+		o.Normalize, o.Alphabet = tc.form, tc.alphabet
+		words, stats, err := LoadDictionary(strings.NewReader(dict), o)
+		if err != nil {
+			t.Errorf("-normalize %q: LoadDictionary: %v", tc.form, err)
+			continue
+		}
+		if !slices.Equal(words, tc.want) {
+			t.Errorf("-normalize %q with alphabet %+q: kept %+q, want %+q", tc.form, tc.alphabet, words, tc.want)
+		}
+		if tc.form != "" && stats.Duplicates != 1 {
+			t.Errorf("-normalize %q: %d duplicates, want the two spellings to be one word", tc.form, stats.Duplicates)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Options are the tunables that decide which puzzles are made and how they
+// are scored. The command line binds each field to a flag.
+type Options struct {
+	// Alphabet lists the letters puzzles are made from, at most 32 of them.
+	Alphabet string
+	// NumLetters is the number of letters in a puzzle.
+	NumLetters int
+	// Lowercase folds the case of dictionary words to the alphabet's before
+	// filtering, instead of rejecting words with capitals; see
+	// foldToAlphabet.
+	Lowercase bool
+	// Normalize is the Unicode normalization form, "NFC" or "NFD", the
+	// alphabet and dictionary words are put in before use; empty means
+	// they are used as is. See Options.normalize.
+	Normalize string
+	// MinVowels and MaxVowels bound the number of vowels, a, e, i, o and u,
+	// and y if VowelY is set, in the letter sets generated. A MaxVowels of 0
+	// means no upper bound.
+	MinVowels, MaxVowels int
+	VowelY               bool
+	// MinWordLen is the length of the shortest answer.
+	MinWordLen int
+	// MaxWordLen is the length of the longest answer; 0 means no limit.
+	MaxWordLen int
+	// MinDistinctLetters is the fewest different letters an answer may
+	// use; 0 means no limit.
+	MinDistinctLetters int
+	// MinWords is the fewest answers a puzzle may have.
+	MinWords int
+	// RequireCenter requires every answer to use the center letter.
+	RequireCenter bool
+	// Centers is the number of center letters, at the start of a letter
+	// set, that answers must use.
+	Centers int
+	// MinPoints and MaxPoints bound a puzzle's total points. A MaxPoints of
+	// 0 means no upper bound.
+	MinPoints, MaxPoints int
+	// MinAvgPoints, if positive, is the fewest points per answer a puzzle
+	// may average.
+	MinAvgPoints float64
+	// MinStartLetters is the fewest different letters a puzzle's answers
+	// may start with; 0 means no limit.
+	MinStartLetters int
+	// PangramFreq, if positive, is how common, by the frequency file, at
+	// least one of a puzzle's pangrams must be.
+	PangramFreq float64
+	// RequireNearPangram requires a puzzle to have an answer that uses all
+	// but one of its letters.
+	RequireNearPangram bool
+	// MaxPangrams is the most pangrams a puzzle may have; 0 means no
+	// limit.
+	MaxPangrams int
+	// MinEasyWords is the fewest easy answers, short and common ones, a
+	// puzzle may have; 0 means no limit. See wordIndex.easy.
+	MinEasyWords int
+
+	// FourLetterScore is the points a four-letter answer earns.
+	FourLetterScore int
+	// PangramBonus is the bonus a pangram earns in "fixed" PangramBonusMode;
+	// -1 means NumLetters.
+	PangramBonus int
+	// PangramBonusMode is "fixed" or "length"; see ScoreWord.
+	PangramBonusMode string
+	// PerfectPangramBonus is the extra bonus for a pangram that uses each
+	// letter exactly once.
+	PerfectPangramBonus int
+	// ScoreExpr, if set, replaces the scoring rules above with an
+	// expression; see parseScoreExpr.
+	ScoreExpr string
+}
+
+const defaultAlphabet = "abcdefghijklmnopqrstuvwxyz"
+
+// DefaultOptions returns the options used when no flags are given.
+func DefaultOptions() Options {
+	return Options{
+		Alphabet:         defaultAlphabet,
+		NumLetters:       7,
+		MinWordLen:       5,
+		MinWords:         10,
+		RequireCenter:    true,
+		Centers:          1,
+		FourLetterScore:  1,
+		PangramBonus:     -1,
+		PangramBonusMode: "fixed",
+	}
+}
+
+// Validate reports the first option that is out of range or inconsistent
+// with the others.
+func (o Options) Validate() error {
+	n := utf8.RuneCountInString(o.Alphabet)
+	seen := map[rune]bool{}
+	repeated := false
+	for _, r := range o.Alphabet {
+		repeated = repeated || seen[r]
+		seen[r] = true
+	}
+	switch {
+	case n == 0:
+		return fmt.Errorf("%w: Alphabet is empty", ErrInvalidOptions)
+	case n > 32:
+		return fmt.Errorf("%w: Alphabet has %d letters, at most 32 are supported", ErrInvalidOptions, n)
+	case repeated:
+		return fmt.Errorf("%w: Alphabet %q has repeated letters", ErrInvalidOptions, o.Alphabet)
+	case o.NumLetters < 1:
+		return fmt.Errorf("%w: NumLetters is %d, want at least 1", ErrInvalidOptions, o.NumLetters)
+	case o.NumLetters > n:
+		return fmt.Errorf("%w: NumLetters is %d, but Alphabet only has %d letters", ErrInvalidOptions, o.NumLetters, n)
+	case o.Centers < 1 || o.Centers > o.NumLetters:
+		return fmt.Errorf("%w: Centers is %d, want 1 to NumLetters (%d)", ErrInvalidOptions, o.Centers, o.NumLetters)
+	case o.MinVowels < 0 || o.MinVowels > o.NumLetters:
+		return fmt.Errorf("%w: MinVowels is %d, want 0 to NumLetters (%d)", ErrInvalidOptions, o.MinVowels, o.NumLetters)
+	case o.MaxVowels < 0:
+		return fmt.Errorf("%w: MaxVowels is %d, want at least 0", ErrInvalidOptions, o.MaxVowels)
+	case o.MaxVowels != 0 && o.MaxVowels < o.MinVowels:
+		return fmt.Errorf("%w: MaxVowels %d is less than MinVowels %d", ErrInvalidOptions, o.MaxVowels, o.MinVowels)
+	case o.MinWordLen < 1:
+		return fmt.Errorf("%w: MinWordLen is %d, want at least 1", ErrInvalidOptions, o.MinWordLen)
+	case o.MaxWordLen < 0:
+		return fmt.Errorf("%w: MaxWordLen is %d, want at least 0", ErrInvalidOptions, o.MaxWordLen)
+	case o.MaxWordLen != 0 && o.MaxWordLen < o.MinWordLen:
+		return fmt.Errorf("%w: MaxWordLen %d is less than MinWordLen %d", ErrInvalidOptions, o.MaxWordLen, o.MinWordLen)
+	case o.MinDistinctLetters < 0 || o.MinDistinctLetters > o.NumLetters:
+		return fmt.Errorf("%w: MinDistinctLetters is %d, want 0 to NumLetters (%d)", ErrInvalidOptions, o.MinDistinctLetters, o.NumLetters)
+	case o.MinWords < 1:
+		return fmt.Errorf("%w: MinWords is %d, want at least 1", ErrInvalidOptions, o.MinWords)
+	case o.MinAvgPoints < 0:
+		return fmt.Errorf("%w: MinAvgPoints is %v, want at least 0", ErrInvalidOptions, o.MinAvgPoints)
+	case o.MinStartLetters < 0:
+		return fmt.Errorf("%w: MinStartLetters is %d, want at least 0", ErrInvalidOptions, o.MinStartLetters)
+	case o.MaxPangrams < 0:
+		return fmt.Errorf("%w: MaxPangrams is %d, want at least 0", ErrInvalidOptions, o.MaxPangrams)
+	case o.MinEasyWords < 0:
+		return fmt.Errorf("%w: MinEasyWords is %d, want at least 0", ErrInvalidOptions, o.MinEasyWords)
+	case o.PangramFreq < 0:
+		return fmt.Errorf("%w: PangramFreq is %v, want at least 0", ErrInvalidOptions, o.PangramFreq)
+	case o.MaxPoints != 0 && o.MaxPoints < o.MinPoints:
+		return fmt.Errorf("%w: MaxPoints %d is less than MinPoints %d", ErrInvalidOptions, o.MaxPoints, o.MinPoints)
+	}
+	if o.ScoreExpr != "" {
+		if _, err := parseScoreExpr(o.ScoreExpr); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidOptions, err)
+		}
+	}
+	if err := validateNormalize(o.Normalize); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidOptions, err)
+	}
+	if err := validatePangramBonusMode(o.PangramBonusMode); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidOptions, err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	if err := DefaultOptions().Validate(); err != nil {
+		t.Errorf("DefaultOptions().Validate() = %v, want nil", err)
+	}
+	for _, tc := range []struct {
+		name string
+		edit func(o *Options)
+		want string
+	}{
+		{"no min words", func(o *Options) { o.MinWords = 0 }, "MinWords"},
+		{"more letters than alphabet", func(o *Options) { o.Alphabet, o.NumLetters = "abcdef", 7 }, "NumLetters"},
+		{"no letters", func(o *Options) { o.NumLetters = 0 }, "NumLetters"},
+		{"empty alphabet", func(o *Options) { o.Alphabet = "" }, "Alphabet"},
+		{"repeated alphabet", func(o *Options) { o.Alphabet = "abcdefga" }, "repeated"},
+		{"long alphabet", func(o *Options) { o.Alphabet = DefaultOptions().Alphabet + "áéíóúñç" }, "at most 32"},
+		{"too many centers", func(o *Options) { o.Centers = 8 }, "Centers"},
+		{"word lengths", func(o *Options) { o.MinWordLen, o.MaxWordLen = 6, 5 }, "MaxWordLen"},
+		{"vowels", func(o *Options) { o.MinVowels, o.MaxVowels = 3, 2 }, "MaxVowels"},
+		{"points", func(o *Options) { o.MinPoints, o.MaxPoints = 100, 50 }, "MaxPoints"},
+		{"bonus mode", func(o *Options) { o.PangramBonusMode = "double" }, "pangram bonus mode"},
+		{"score expression", func(o *Options) { o.ScoreExpr = "len +" }, "score expression"},
+		{"normalization", func(o *Options) { o.Normalize = "NFKC" }, "NFKC"},
+	} {
+		o := DefaultOptions()
+		tc.edit(&o)
+		err := o.Validate()
+		if !errors.Is(err, ErrInvalidOptions) || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: Validate() = %v, want ErrInvalidOptions mentioning %q", tc.name, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,550 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"unicode/utf8"
+)
+
+// A puzzleWriter writes puzzles in one output format.
+type puzzleWriter interface {
+	// write writes p and returns the name of the file it went to. Writers
+	// of a file per puzzle retry transient failures, with retryWrite, until
+	// ctx is done; writers of one file for every puzzle never retry, so no
+	// record is written twice.
+	write(ctx context.Context, p puzzle) (string, error)
+	// close flushes anything buffered and closes open files.
+	close() error
+}
+
+// isStreamFormat reports whether format writes every puzzle to one file.
+func isStreamFormat(format string) bool {
+	switch format {
+	case "json", "csv", "yaml", "flat", "hints":
+		return true
+	}
+	return false
+}
+
+// newPuzzleWriter returns a writer for -format, which may list several
+// formats separated by commas.
+func newPuzzleWriter() (puzzleWriter, error) {
+	if *outputCase != "lower" && *outputCase != "upper" {
+		return nil, fmt.Errorf("unknown output case %q, want lower or upper", *outputCase)
+	}
+	formats := strings.Split(*format, ",")
+	seen := map[string]bool{}
+	streams := 0
+	for _, f := range formats {
+		if seen[f] {
+			return nil, fmt.Errorf("format %q is listed twice in -format", f)
+		}
+		seen[f] = true
+		if isStreamFormat(f) {
+			streams++
+		}
+	}
+	if *gzipOutput && streams == 0 {
+		return nil, fmt.Errorf("-gzip_output needs -format json, csv, yaml, flat or hints")
+	}
+	if name, ok := strings.CutPrefix(*output, archivePrefix); ok {
+		if streams > 0 {
+			return nil, fmt.Errorf("-output %sFILE needs -format txt or png", archivePrefix)
+		}
+		return newZipWriter(name, formats)
+	}
+	if *output != "" && streams > 1 {
+		return nil, fmt.Errorf("-output can't be used with more than one of json, csv, yaml, flat and hints in -format")
+	}
+	if len(formats) == 1 {
+		return newFormatWriter(formats[0])
+	}
+
+	mw := multiWriter{}
+	for _, f := range formats {
+		pw, err := newFormatWriter(f)
+		if err != nil {
+			mw.close()
+			return nil, err
+		}
+		mw = append(mw, pw)
+	}
+	return mw, nil
+}
+
+// newFormatWriter returns a writer for one output format.
+func newFormatWriter(format string) (puzzleWriter, error) {
+	switch format {
+	case "txt":
+		if *maxOpenFiles < 1 {
+			return nil, fmt.Errorf("-max_open_files is %d, want at least 1", *maxOpenFiles)
+		}
+		w := newTxtWriter(*outDir, *shardOutputByCenter, *txtHeader, *scoreFirst, *maxOpenFiles)
+		if *markPangrams {
+			w.mark = *pangramMark
+		}
+		return w, nil
+	case "png":
+		return &pngWriter{dir: *outDir, shard: *shardOutputByCenter}, nil
+	case "json", "csv", "yaml", "flat", "hints":
+		path := *output
+		if path == "" {
+			path = filepath.Join(*outDir, "puzzles."+format)
+			if *gzipOutput {
+				path += ".gz"
+			}
+		}
+		s, err := openStream(path, *gzipOutput)
+		if err != nil {
+			return nil, err
+		}
+		switch format {
+		case "json":
+			return &jsonWriter{stream: s, enc: json.NewEncoder(s.w)}, nil
+		case "yaml":
+			return &yamlWriter{stream: s}, nil
+		case "flat":
+			return &flatWriter{stream: s}, nil
+		case "hints":
+			return &hintsWriter{stream: s}, nil
+		}
+		cw := csv.NewWriter(s.w)
+		if err := cw.Write([]string{"letters", "center", "words", "maxPoints"}); err != nil {
+			s.close()
+			return nil, err
+		}
+		return &csvWriter{stream: s, cw: cw}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q, want txt, png, json, csv, yaml, flat or hints", format)
+}
+
+// A multiWriter writes each puzzle with several writers. The file name it
+// returns is the first writer's.
+type multiWriter []puzzleWriter
+
+func (mw multiWriter) write(ctx context.Context, p puzzle) (string, error) {
+	name := ""
+	for i, pw := range mw {
+		fn, err := pw.write(ctx, p)
+		if err != nil {
+			return "", err
+		}
+		if i == 0 {
+			name = fn
+		}
+	}
+	return name, nil
+}
+
+func (mw multiWriter) close() error {
+	var first error
+	for _, pw := range mw {
+		if err := pw.close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// puzzleRecord is the JSON form of a puzzle.
+type puzzleRecord struct {
+	Letters   string   `json:"letters"`
+	Center    string   `json:"center"`
+	Outer     []string `json:"outer"` // the letters other than the center
+	Words     []string `json:"words"`
+	MaxPoints int      `json:"maxPoints"`
+
+	// LongestWordLen is the length, in letters, of the longest answer.
+	LongestWordLen int `json:"longestWordLen"`
+	// NearPangrams lists the answers that use all but one of the letters.
+	NearPangrams []string `json:"nearPangrams,omitempty"`
+	// Difficulty maps each answer to common, uncommon or rare, by how
+	// frequent it is in -freq_file. It is only set with a -freq_file.
+	Difficulty map[string]string `json:"difficulty,omitempty"`
+}
+
+func newPuzzleRecord(p puzzle) puzzleRecord {
+	return puzzleRecord{
+		Letters:        p.letters,
+		Center:         centerLetter(p.letters),
+		Outer:          outerLetters(p.letters),
+		Words:          p.words,
+		MaxPoints:      p.maxPts,
+		LongestWordLen: longestWordLen(p.words),
+		NearPangrams:   nearPangrams(p.words, p.letters),
+		Difficulty:     p.difficulty,
+	}
+}
+
+// longestWordLen returns the length in letters of the longest of words.
+func longestWordLen(words []string) int {
+	n := 0
+	for _, w := range words {
+		n = max(n, utf8.RuneCountInString(w))
+	}
+	return n
+}
+
+// outerLetters returns the letters of the letter set s after its center,
+// each as its own string.
+func outerLetters(s string) []string {
+	outer := []string{}
+	for _, r := range strings.TrimPrefix(s, centerLetter(s)) {
+		outer = append(outer, string(r))
+	}
+	return outer
+}
+
+// outputCased returns p with its letters and answers in -output_case. Puzzles
+// are always matched in lowercase; this only changes what is written.
+func outputCased(p puzzle) puzzle {
+	if *outputCase != "upper" {
+		return p
+	}
+	words := make([]string, len(p.words))
+	for i, w := range p.words {
+		words[i] = strings.ToUpper(w)
+	}
+	if p.difficulty != nil {
+		difficulty := make(map[string]string, len(p.difficulty))
+		for w, d := range p.difficulty {
+			difficulty[strings.ToUpper(w)] = d
+		}
+		p.difficulty = difficulty
+	}
+	p.letters = strings.ToUpper(p.letters)
+	p.words = words
+	return p
+}
+
+// anagramOrder returns words reordered so that anagrams, words with the same
+// letters, are next to each other. Groups are in the order of their first
+// word in words.
+func anagramOrder(words []string) []string {
+	groups := map[string][]string{}
+	keys := []string{}
+	for _, w := range words {
+		rs := []rune(w)
+		sort.Slice(rs, func(i, j int) bool { return rs[i] < rs[j] })
+		k := string(rs)
+		if _, found := groups[k]; !found {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], w)
+	}
+	ordered := make([]string, 0, len(words))
+	for _, k := range keys {
+		ordered = append(ordered, groups[k]...)
+	}
+	return ordered
+}
+
+// txtWriter writes each puzzle to its own file in dir: the answers one per
+// line, then the puzzle's points. File names are always lowercase. If shard
+// is set, each file goes in a subdirectory named for its center letter. If
+// header is set, the answers are preceded by "center: X" and "letters: XYZ"
+// lines. If scoreFirst is set, the points come before the answers. If mark
+// is set, it follows each pangram, after a space.
+//
+// A txtWriter is safe for concurrent use. It keeps at most cap(open) files
+// open at once, and reuses buffered writers between files.
+type txtWriter struct {
+	dir        string
+	shard      bool
+	header     bool
+	scoreFirst bool
+	mark       string
+	open       chan struct{}
+	bufs       sync.Pool
+}
+
+func newTxtWriter(dir string, shard, header, scoreFirst bool, maxOpen int) *txtWriter {
+	return &txtWriter{dir: dir, shard: shard, header: header, scoreFirst: scoreFirst, open: make(chan struct{}, maxOpen)}
+}
+
+func (w *txtWriter) write(ctx context.Context, p puzzle) (string, error) {
+	fn := p.letters + ".txt"
+	if w.shard {
+		fn = filepath.Join(centerLetter(p.letters), fn)
+		if err := os.MkdirAll(filepath.Join(w.dir, filepath.Dir(fn)), 0755); err != nil {
+			return "", err
+		}
+	}
+	if err := retryWrite(ctx, p.letters, func() error { return w.writeFile(fn, p) }); err != nil {
+		return "", err
+	}
+	return fn, nil
+}
+
+// writeFile writes p to the file fn in w.dir, replacing it if it exists.
+func (w *txtWriter) writeFile(fn string, p puzzle) error {
+	w.open <- struct{}{}
+	defer func() { <-w.open }()
+	f, err := os.Create(filepath.Join(w.dir, fn))
+	if err != nil {
+		return fmt.Errorf("Create(%q): %w", fn, err)
+	}
+	b, _ := w.bufs.Get().(*bufio.Writer)
+	if b == nil {
+		b = bufio.NewWriter(f)
+	} else {
+		b.Reset(f)
+	}
+	defer w.bufs.Put(b)
+	w.writeTo(b, p)
+	if err := b.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeTo writes p to b in the txtWriter's layout. Errors are left for
+// b.Flush to report.
+func (w *txtWriter) writeTo(b *bufio.Writer, p puzzle) {
+	cp := outputCased(p)
+	if w.header {
+		fmt.Fprintf(b, "center: %s\nletters: %s\n", centerLetter(cp.letters), cp.letters)
+	}
+	if w.scoreFirst {
+		fmt.Fprintln(b, p.maxPts)
+	}
+	for _, word := range cp.words {
+		if w.mark != "" && isPangram(word, cp.letters) {
+			fmt.Fprintln(b, word, w.mark)
+			continue
+		}
+		fmt.Fprintln(b, word)
+	}
+	if !w.scoreFirst {
+		fmt.Fprintln(b, p.maxPts)
+	}
+}
+
+func (*txtWriter) close() error { return nil }
+
+// pngWriter draws each puzzle's hive, as the render command does, to its own
+// PNG file in dir, optionally in a subdirectory for its center letter.
+type pngWriter struct {
+	dir   string
+	shard bool
+}
+
+func (w *pngWriter) write(ctx context.Context, p puzzle) (string, error) {
+	fn := p.letters + ".png"
+	if w.shard {
+		fn = filepath.Join(centerLetter(p.letters), fn)
+		if err := os.MkdirAll(filepath.Join(w.dir, filepath.Dir(fn)), 0755); err != nil {
+			return "", err
+		}
+	}
+	img := renderHive(p.letters, pngSize)
+	err := retryWrite(ctx, p.letters, func() error {
+		f, err := os.Create(filepath.Join(w.dir, fn))
+		if err != nil {
+			return err
+		}
+		if err := png.Encode(f, img); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	})
+	if err != nil {
+		return "", err
+	}
+	return fn, nil
+}
+
+func (*pngWriter) close() error { return nil }
+
+// A stream is a single output file that every puzzle is written to, gzipped
+// if requested. Writes to w are buffered until close.
+type stream struct {
+	name string
+	f    *os.File
+	gz   *gzip.Writer
+	buf  *bufio.Writer
+	w    io.Writer
+}
+
+func openStream(path string, compress bool) (*stream, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &stream{name: filepath.Base(path), f: f}
+	if compress {
+		s.gz = gzip.NewWriter(f)
+		s.buf = bufio.NewWriter(s.gz)
+	} else {
+		s.buf = bufio.NewWriter(f)
+	}
+	s.w = s.buf
+	return s, nil
+}
+
+func (s *stream) close() error {
+	if err := s.buf.Flush(); err != nil {
+		s.f.Close()
+		return err
+	}
+	if s.gz != nil {
+		if err := s.gz.Close(); err != nil {
+			s.f.Close()
+			return err
+		}
+	}
+	return s.f.Close()
+}
+
+// jsonWriter writes puzzles as newline-delimited JSON records.
+type jsonWriter struct {
+	*stream
+	enc *json.Encoder
+}
+
+func (w *jsonWriter) write(_ context.Context, p puzzle) (string, error) {
+	return w.name, w.enc.Encode(newPuzzleRecord(outputCased(p)))
+}
+
+// csvWriter writes puzzles as CSV rows, with the answers space-separated in
+// one column.
+type csvWriter struct {
+	*stream
+	cw *csv.Writer
+}
+
+func (w *csvWriter) write(_ context.Context, p puzzle) (string, error) {
+	p = outputCased(p)
+	err := w.cw.Write([]string{p.letters, centerLetter(p.letters), strings.Join(p.words, " "), strconv.Itoa(p.maxPts)})
+	return w.name, err
+}
+
+func (w *csvWriter) close() error {
+	w.cw.Flush()
+	if err := w.cw.Error(); err != nil {
+		w.stream.close()
+		return err
+	}
+	return w.stream.close()
+}
+
+// yamlWriter writes each puzzle as a YAML document. Strings are always
+// double-quoted, so answers like "null" or "false" stay strings.
+type yamlWriter struct {
+	*stream
+}
+
+func (w *yamlWriter) write(_ context.Context, p puzzle) (string, error) {
+	p = outputCased(p)
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "letters: %s\n", strconv.Quote(p.letters))
+	fmt.Fprintf(&b, "center: %s\n", strconv.Quote(centerLetter(p.letters)))
+	b.WriteString("words:\n")
+	for _, word := range p.words {
+		fmt.Fprintf(&b, "  - %s\n", strconv.Quote(word))
+	}
+	fmt.Fprintf(&b, "points: %d\n", p.maxPts)
+	_, err := io.WriteString(w.w, b.String())
+	return w.name, err
+}
+
+// flatWriter writes each puzzle on one line: its letters, center letter,
+// answers and points, separated by spaces.
+type flatWriter struct {
+	*stream
+}
+
+func (w *flatWriter) write(_ context.Context, p puzzle) (string, error) {
+	p = outputCased(p)
+	fields := append([]string{p.letters, centerLetter(p.letters)}, p.words...)
+	fields = append(fields, strconv.Itoa(p.maxPts))
+	_, err := fmt.Fprintln(w.w, strings.Join(fields, " "))
+	return w.name, err
+}
+
+// hintsWriter writes hints for each puzzle that don't give its answers away,
+// like the grid the Times shows: the puzzle's letters, its numbers of
+// answers, points and pangrams, and a table counting the answers by first
+// letter and length, with totals. Puzzles are separated by a blank line.
+type hintsWriter struct {
+	*stream
+}
+
+func (w *hintsWriter) write(_ context.Context, p puzzle) (string, error) {
+	p = outputCased(p)
+	pangrams := 0
+	grid := map[rune]map[int]int{}
+	byLen := map[int]int{}
+	for _, word := range p.words {
+		if isPangram(word, p.letters) {
+			pangrams++
+		}
+		r, _ := utf8.DecodeRuneInString(word)
+		n := utf8.RuneCountInString(word)
+		if grid[r] == nil {
+			grid[r] = map[int]int{}
+		}
+		grid[r][n]++
+		byLen[n]++
+	}
+	starts := []rune{}
+	for r := range grid {
+		starts = append(starts, r)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+	lens := []int{}
+	for n := range byLen {
+		lens = append(lens, n)
+	}
+	sort.Ints(lens)
+	cell := func(n int) string {
+		if n == 0 {
+			return "-"
+		}
+		return strconv.Itoa(n)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (center %s)\n", p.letters, centerLetter(p.letters))
+	fmt.Fprintf(&b, "words: %d, points: %d, pangrams: %d\n\n", len(p.words), p.maxPts, pangrams)
+	tw := tabwriter.NewWriter(&b, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(tw, "\t")
+	for _, n := range lens {
+		fmt.Fprintf(tw, "%d\t", n)
+	}
+	fmt.Fprintln(tw, "Σ\t")
+	for _, r := range starts {
+		fmt.Fprintf(tw, "%c:\t", r)
+		total := 0
+		for _, n := range lens {
+			fmt.Fprintf(tw, "%s\t", cell(grid[r][n]))
+			total += grid[r][n]
+		}
+		fmt.Fprintf(tw, "%d\t\n", total)
+	}
+	fmt.Fprint(tw, "Σ:\t")
+	for _, n := range lens {
+		fmt.Fprintf(tw, "%d\t", byLen[n])
+	}
+	fmt.Fprintf(tw, "%d\t\n", len(p.words))
+	tw.Flush()
+	b.WriteString("\n")
+	_, err := io.WriteString(w.w, b.String())
+	return w.name, err
+}
@@ -0,0 +1,546 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nickgraffis/spelling-bee/spellingbeetest"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// failingWriter is a puzzleWriter whose writes fail with err.
+type failingWriter struct{ err error }
+
+func (w failingWriter) write(context.Context, puzzle) (string, error) { return "", w.err }
+func (failingWriter) close() error                                    { return nil }
+
+// writeFormat writes ps with -format f, and the other flags as they are, to a
+// new directory, which it returns.
+func writeFormat(t testing.TB, f string, ps ...puzzle) string {
+	t.Helper()
+	setFlag(t, v, false)
+	setFlag(t, outDir, t.TempDir())
+	setFlag(t, output, "")
+	setFlag(t, format, f)
+	pw, err := newPuzzleWriter()
+	if err != nil {
+		t.Fatalf("newPuzzleWriter with -format %q: %v", f, err)
+	}
+	in := make(chan puzzle, len(ps))
+	for _, p := range ps {
+		in <- p
+	}
+	close(in)
+	if _, err := writePuzzles(context.Background(), in, pw); err != nil {
+		t.Fatalf("writePuzzles: %v", err)
+	}
+	return *outDir
+}
+
+// readOutput returns the contents of the file name in dir.
+func readOutput(t *testing.T, dir, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// samplePuzzle returns spellingbeetest.SamplePuzzle as a puzzle.
+func samplePuzzle() puzzle {
+	sp := spellingbeetest.SamplePuzzle()
+	return puzzle{letters: sp.Letters, words: sp.Words, maxPts: sp.MaxPoints}
+}
+
+func TestGzipOutputMatchesUncompressed(t *testing.T) {
+	ps := []puzzle{samplePuzzle(), {letters: "paelrst", words: []string{"plaster"}, maxPts: 14}}
+	setFlag(t, gzipOutput, false)
+	plain := readOutput(t, writeFormat(t, "json", ps...), "puzzles.json")
+	setFlag(t, gzipOutput, true)
+	zr, err := gzip.NewReader(bytes.NewReader(readOutput(t, writeFormat(t, "json", ps...), "puzzles.json.gz")))
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	unzipped, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("reading gzipped output: %v", err)
+	}
+	if !bytes.Equal(unzipped, plain) {
+		t.Errorf("gzipped output decompresses to\n%s\nwant\n%s", unzipped, plain)
+	}
+	dec := json.NewDecoder(bytes.NewReader(unzipped))
+	for i := 0; dec.More(); i++ {
+		var r puzzleRecord
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("record %d isn't JSON: %v", i, err)
+		}
+		if i >= len(ps) || r.Letters != ps[i].letters {
+			t.Errorf("record %d is %q, want %+v", i, r.Letters, ps)
+		}
+	}
+}
+
+func TestOutputCaseUpper(t *testing.T) {
+	ps := matchAll(sampleIndex(t), "aelprst")
+	if len(ps) != 1 {
+		t.Fatalf("matchWords made %d puzzles from aelprst, want 1", len(ps))
+	}
+	setFlag(t, outputCase, "upper")
+
+	var r puzzleRecord
+	if err := json.Unmarshal(readOutput(t, writeFormat(t, "json", ps...), "puzzles.json"), &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Letters != "AELPRST" || r.Center != "A" || len(r.Words) != 12 || r.Words[0] != "APPLE" {
+		t.Errorf("with -output_case upper, wrote %+v, want the letters and answers in uppercase", r)
+	}
+
+	txt := string(readOutput(t, writeFormat(t, "txt", ps...), "aelprst.txt"))
+	if !strings.HasPrefix(txt, "APPLE\nPASTA\n") || !strings.HasSuffix(txt, "\n70\n") {
+		t.Errorf("with -output_case upper, aelprst.txt is:\n%s\nwant uppercase answers", txt)
+	}
+}
+
+func TestShardOutputByCenter(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	setFlag(t, shardOutputByCenter, true)
+	dir := writeFormat(t, "txt", samplePuzzle(), puzzle{letters: "paelrst", words: []string{"plaster"}, maxPts: 14})
+	for _, fn := range []string{"a/aelprst.txt", "p/paelrst.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, fn)); err != nil {
+			t.Errorf("with -shard_output_by_center: %v", err)
+		}
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.txt")); len(files) != 0 {
+		t.Errorf("with -shard_output_by_center, wrote %q outside the center directories", files)
+	}
+}
+
+// yamlPuzzle is a puzzle read back from -format yaml.
+type yamlPuzzle struct {
+	letters, center string
+	words           []string
+	points          int
+}
+
+// parseYAMLPuzzles parses the documents yamlWriter writes.
+func parseYAMLPuzzles(t *testing.T, b []byte) []yamlPuzzle {
+	t.Helper()
+	ps := []yamlPuzzle{}
+	for _, doc := range strings.Split(string(b), "---\n")[1:] {
+		var p yamlPuzzle
+		for _, l := range strings.Split(strings.TrimSuffix(doc, "\n"), "\n") {
+			key, val, _ := strings.Cut(l, ": ")
+			if w, ok := strings.CutPrefix(l, "  - "); ok {
+				key, val = "-", w
+			}
+			var err error
+			switch key {
+			case "letters":
+				p.letters, err = strconv.Unquote(val)
+			case "center":
+				p.center, err = strconv.Unquote(val)
+			case "words:":
+			case "-":
+				var w string
+				w, err = strconv.Unquote(val)
+				p.words = append(p.words, w)
+			case "points":
+				p.points, err = strconv.Atoi(val)
+			default:
+				t.Fatalf("unexpected YAML line %q", l)
+			}
+			if err != nil {
+				t.Fatalf("YAML line %q: %v", l, err)
+			}
+		}
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+func TestYAMLOutput(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	ps := []puzzle{samplePuzzle(), {letters: "nulabcd", words: []string{"null", "nulla"}, maxPts: 6}}
+	got := parseYAMLPuzzles(t, readOutput(t, writeFormat(t, "yaml", ps...), "puzzles.yaml"))
+	if len(got) != len(ps) {
+		t.Fatalf("read back %d YAML puzzles, want %d", len(got), len(ps))
+	}
+	for i, p := range ps {
+		want := yamlPuzzle{letters: p.letters, center: p.letters[:1], words: p.words, points: p.maxPts}
+		if !reflect.DeepEqual(got[i], want) {
+			t.Errorf("YAML puzzle %d = %+v, want %+v", i, got[i], want)
+		}
+	}
+}
+
+func TestTxtHeader(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	setFlag(t, txtHeader, true)
+	p := puzzle{letters: "paelrst", words: []string{"plaster"}, maxPts: 14}
+	got := string(readOutput(t, writeFormat(t, "txt", p), "paelrst.txt"))
+	if want := "center: p\nletters: paelrst\nplaster\n14\n"; got != want {
+		t.Errorf("with -txt_header, paelrst.txt is %q, want %q", got, want)
+	}
+}
+
+func TestGroupAnagrams(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	words := []string{"dear", "adder", "read", "dread", "dare", "ready"}
+	want := []string{"dear", "read", "dare", "adder", "dread", "ready"}
+	if got := anagramOrder(words); !reflect.DeepEqual(got, want) {
+		t.Errorf("anagramOrder(%q) = %q, want %q", words, got, want)
+	}
+
+	setFlag(t, groupAnagrams, true)
+	p := puzzle{letters: "daery", words: words, maxPts: 6}
+	got := string(readOutput(t, writeFormat(t, "txt", p), "daery.txt"))
+	if w := strings.Join(want, "\n") + "\n6\n"; got != w {
+		t.Errorf("with -group_anagrams, daery.txt is %q, want %q", got, w)
+	}
+}
+
+func TestFlatOutput(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	ps := []puzzle{samplePuzzle(), {letters: "paelrst", words: []string{"plaster"}, maxPts: 14}}
+	lines := strings.Split(strings.TrimSuffix(string(readOutput(t, writeFormat(t, "flat", ps...), "puzzles.flat")), "\n"), "\n")
+	if len(lines) != len(ps) {
+		t.Fatalf("wrote %d flat lines, want %d", len(lines), len(ps))
+	}
+	for i, p := range ps {
+		fields := strings.Fields(lines[i])
+		pts, err := strconv.Atoi(fields[len(fields)-1])
+		if err != nil || fields[0] != p.letters || fields[1] != p.letters[:1] || pts != p.maxPts || !reflect.DeepEqual(fields[2:len(fields)-1], p.words) {
+			t.Errorf("flat line %q doesn't parse back to %+v", lines[i], p)
+		}
+	}
+}
+
+func TestMultipleFormats(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	ps := []puzzle{samplePuzzle(), {letters: "paelrst", words: []string{"plaster"}, maxPts: 14}}
+	dir := writeFormat(t, "json,png", ps...)
+	dec := json.NewDecoder(bytes.NewReader(readOutput(t, dir, "puzzles.json")))
+	for _, p := range ps {
+		var r puzzleRecord
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("decoding puzzles.json: %v", err)
+		}
+		if r.Letters != p.letters || r.MaxPoints != p.maxPts {
+			t.Errorf("puzzles.json has %s with %d points, want %s with %d", r.Letters, r.MaxPoints, p.letters, p.maxPts)
+		}
+		if b := readOutput(t, dir, p.letters+".png"); !bytes.HasPrefix(b, []byte("\x89PNG")) {
+			t.Errorf("%s.png isn't a PNG", p.letters)
+		}
+	}
+	if dec.More() {
+		t.Errorf("puzzles.json has more than %d puzzles", len(ps))
+	}
+}
+
+// TestTxtGolden generates the sample puzzle and compares its txt file,
+// byte for byte, with testdata/aelprst.txt.golden. Run the test with
+// -update to rewrite the golden file after changing the format on purpose.
+func TestTxtGolden(t *testing.T) {
+	ps := matchAll(sampleIndex(t), "aelprst")
+	if len(ps) != 1 {
+		t.Fatalf("made %d puzzles from aelprst, want 1", len(ps))
+	}
+	got := readOutput(t, writeFormat(t, "txt", ps...), "aelprst.txt")
+	golden := filepath.Join("testdata", "aelprst.txt.golden")
+	if *update {
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("aelprst.txt is\n%s\nwant, from %s,\n%s", got, golden, want)
+	}
+}
+
+func TestScoreFirst(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	p := puzzle{letters: "paelrst", words: []string{"plaster", "plates"}, maxPts: 20}
+	if got, want := string(readOutput(t, writeFormat(t, "txt", p), "paelrst.txt")), "plaster\nplates\n20\n"; got != want {
+		t.Errorf("by default, paelrst.txt is %q, want %q", got, want)
+	}
+	setFlag(t, scoreFirst, true)
+	if got, want := string(readOutput(t, writeFormat(t, "txt", p), "paelrst.txt")), "20\nplaster\nplates\n"; got != want {
+		t.Errorf("with -score_first, paelrst.txt is %q, want %q", got, want)
+	}
+}
+
+func TestRetryWriteRetriesTransientErrors(t *testing.T) {
+	calls := 0
+	err := retryWrite(context.Background(), "aelprst", func() error {
+		if calls++; calls < 3 {
+			return syscall.EIO
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("retryWrite = %v after %d calls, want nil after 3", err, calls)
+	}
+
+	calls = 0
+	err = retryWrite(context.Background(), "aelprst", func() error {
+		calls++
+		return syscall.ENOSPC
+	})
+	if !errors.Is(err, syscall.ENOSPC) || calls != 1 {
+		t.Errorf("retryWrite = %v after %d calls, want ENOSPC after 1", err, calls)
+	}
+}
+
+func TestRetryWriteStopsWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+	start := time.Now()
+	err := retryWrite(ctx, "aelprst", func() error {
+		calls++
+		return syscall.EAGAIN
+	})
+	if !errors.Is(err, syscall.EAGAIN) || calls != 1 {
+		t.Errorf("retryWrite = %v after %d calls, want EAGAIN after 1", err, calls)
+	}
+	if d := time.Since(start); d >= writeBackoff {
+		t.Errorf("retryWrite took %v with ctx done, want less than %v", d, writeBackoff)
+	}
+}
+
+func TestMultiWriterDoesNotRepeatStreamRecords(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	path := filepath.Join(t.TempDir(), "puzzles.json")
+	s, err := openStream(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mw := multiWriter{&jsonWriter{stream: s, enc: json.NewEncoder(s.w)}, failingWriter{syscall.EIO}}
+	p := puzzle{letters: "aelprst", words: []string{"plaster"}, maxPts: 14}
+	if _, err := mw.write(context.Background(), p); !errors.Is(err, syscall.EIO) {
+		t.Errorf("write = %v, want EIO", err)
+	}
+	if err := mw.close(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(b), "\n"); n != 1 {
+		t.Errorf("wrote %d JSON records, want 1:\n%s", n, b)
+	}
+}
+
+// manyPuzzles returns n copies of the sample puzzle, each with letters of
+// its own so they are written to different files.
+func manyPuzzles(n int) []puzzle {
+	ps := make([]puzzle, n)
+	for i := range ps {
+		ps[i] = samplePuzzle()
+		ps[i].letters = fmt.Sprintf("%s%d", ps[i].letters, i)
+	}
+	return ps
+}
+
+func TestBufferedJSONFlushedOnClose(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	// Far more than one buffer's worth, so records straddle flushes.
+	ps := manyPuzzles(1000)
+	dec := json.NewDecoder(bytes.NewReader(readOutput(t, writeFormat(t, "json", ps...), "puzzles.json")))
+	n := 0
+	for ; dec.More(); n++ {
+		var r puzzleRecord
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("decoding record %d: %v", n, err)
+		}
+		if r.Letters != ps[n].letters || len(r.Words) != len(ps[n].words) {
+			t.Fatalf("record %d is %s with %d words, want %s with %d", n, r.Letters, len(r.Words), ps[n].letters, len(ps[n].words))
+		}
+	}
+	if n != len(ps) {
+		t.Errorf("puzzles.json has %d records, want %d", n, len(ps))
+	}
+}
+
+func BenchmarkWritePuzzles(b *testing.B) {
+	for _, f := range []string{"txt", "json"} {
+		b.Run(f, func(b *testing.B) {
+			setOpts(b, DefaultOptions())
+			ps := manyPuzzles(b.N)
+			b.ReportAllocs()
+			b.ResetTimer()
+			writeFormat(b, f, ps...)
+		})
+	}
+}
+
+func TestJSONCenterAndOuter(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	var r puzzleRecord
+	if err := json.Unmarshal(readOutput(t, writeFormat(t, "json", samplePuzzle()), "puzzles.json"), &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Center != "a" || slices.Contains(r.Outer, r.Center) {
+		t.Errorf("center %q and outer %q, want center a and outer without it", r.Center, r.Outer)
+	}
+	if got := r.Center + strings.Join(r.Outer, ""); got != r.Letters {
+		t.Errorf("center %q and outer %q make %q, want the letters %q", r.Center, r.Outer, got, r.Letters)
+	}
+}
+
+func TestLongestWordLen(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	for _, tc := range []struct {
+		words []string
+		want  int
+	}{
+		{spellingbeetest.SamplePuzzle().Words, 7},
+		{[]string{"pleat", "plates", "sepal"}, 6},
+		{[]string{"école", "écoles"}, 6},
+	} {
+		if got := newPuzzleRecord(puzzle{letters: "aelprst", words: tc.words}).LongestWordLen; got != tc.want {
+			t.Errorf("longestWordLen of %q = %d, want %d", tc.words, got, tc.want)
+		}
+	}
+}
+
+func TestMarkPangrams(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	setFlag(t, markPangrams, true)
+	p := puzzle{letters: "aelprst", words: []string{"plaster", "plates", "stapler"}, maxPts: 34}
+	for mark, want := range map[string]string{
+		"*":  "plaster *\nplates\nstapler *\n34\n",
+		"!!": "plaster !!\nplates\nstapler !!\n34\n",
+	} {
+		setFlag(t, pangramMark, mark)
+		if got := string(readOutput(t, writeFormat(t, "txt", p), "aelprst.txt")); got != want {
+			t.Errorf("with -mark_pangrams and -pangram_mark %q, aelprst.txt is %q, want %q", mark, got, want)
+		}
+	}
+}
+
+func TestWritePuzzlesCancelledLeavesWholeFiles(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	setFlag(t, v, false)
+	dir := t.TempDir()
+	setFlag(t, outDir, dir)
+	setFlag(t, output, "")
+	setFlag(t, format, "txt,json")
+	pw, err := newPuzzleWriter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	in := make(chan puzzle)
+	go func() {
+		defer close(in)
+		for i, p := range manyPuzzles(10000) {
+			if i == 50 {
+				cancel()
+			}
+			select {
+			case in <- p:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	written, err := writePuzzles(ctx, in, pw)
+	if err != nil {
+		t.Fatalf("writePuzzles: %v", err)
+	}
+	if len(written) == 0 || len(written) > 51 {
+		t.Fatalf("writePuzzles wrote %d puzzles after being cancelled at the 51st, want 1 to 51", len(written))
+	}
+
+	want := readOutput(t, writeFormat(t, "txt", samplePuzzle()), "aelprst.txt")
+	for _, e := range written {
+		if got := readOutput(t, dir, e.File); !bytes.Equal(got, want) {
+			t.Errorf("%s is %q, want the whole puzzle %q", e.File, got, want)
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(readOutput(t, dir, "puzzles.json")))
+	n := 0
+	for ; dec.More(); n++ {
+		var r puzzleRecord
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("puzzles.json record %d: %v", n, err)
+		}
+	}
+	if n != len(written) {
+		t.Errorf("puzzles.json has %d records, want the %d puzzles written", n, len(written))
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.txt")); len(files) != len(written) {
+		t.Errorf("wrote %d txt files, want the %d in the manifest", len(files), len(written))
+	}
+}
+
+func TestHintsGridMatchesAnswers(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	p := samplePuzzle()
+	out := string(readOutput(t, writeFormat(t, "hints", p), "puzzles.hints"))
+	for _, w := range p.words {
+		if strings.Contains(out, w) {
+			t.Errorf("the hints give away the answer %q:\n%s", w, out)
+		}
+	}
+	if !strings.Contains(out, "words: 12, points: 70, pangrams: 1\n") {
+		t.Errorf("the hints lack the puzzle's totals:\n%s", out)
+	}
+
+	// Count the answers by first letter and length, as the grid should.
+	want := map[string]int{}
+	for _, w := range p.words {
+		first, n := w[:1]+":", strconv.Itoa(len(w))
+		want[first+n]++
+		want[first+"Σ"]++
+		want["Σ:"+n]++
+		want["Σ:Σ"]++
+	}
+	got := map[string]int{}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	var header []string
+	for _, l := range lines[3:] {
+		fields := strings.Fields(l)
+		if !strings.HasSuffix(fields[0], ":") {
+			header = fields
+			continue
+		}
+		for i, f := range fields[1:] {
+			if f == "-" {
+				continue
+			}
+			n, err := strconv.Atoi(f)
+			if err != nil {
+				t.Fatalf("bad count %q in the hints line %q", f, l)
+			}
+			got[fields[0]+header[i]] = n
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("the hints grid counts %v, want %v from the answers; hints:\n%s", got, want, out)
+	}
+}
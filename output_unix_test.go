@@ -0,0 +1,50 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+func TestTxtWriterStaysUnderOpenFileLimit(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	fds, err := os.ReadDir("/dev/fd")
+	if err != nil {
+		t.Skipf("can't count open files: %v", err)
+	}
+	var lim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &lim); err != nil {
+		t.Fatal(err)
+	}
+	low := lim
+	low.Cur = uint64(len(fds) + 8)
+	if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, &low); err != nil {
+		t.Skipf("can't lower the open file limit: %v", err)
+	}
+	defer syscall.Setrlimit(syscall.RLIMIT_NOFILE, &lim)
+
+	// Far more puzzles than the limit, all written at once.
+	w := newTxtWriter(t.TempDir(), false, false, false, 4)
+	var wg sync.WaitGroup
+	errs := make(chan error, 200)
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := puzzle{letters: fmt.Sprintf("p%03d", i), words: []string{"plaster"}, maxPts: 14}
+			if _, err := w.write(context.Background(), p); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("write with %d files open at most and a limit of %d: %v", 4, low.Cur, err)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log/slog"
+	"math"
+	"os"
+	"unicode"
+)
+
+var renderFlags = flag.NewFlagSet("render", flag.ContinueOnError)
+
+var (
+	renderLetters = renderFlags.String("letters", "", "Letter set to draw, center letter first")
+	renderOut     = renderFlags.String("out", "", "PNG file to write (default LETTERS.png)")
+	renderSize    = renderFlags.Int("size", pngSize, "Width and height of the image in pixels")
+)
+
+// pngSize is the default width and height of a hive image, and the size of
+// those written by -format png.
+const pngSize = 400
+
+var (
+	centerColor = color.RGBA{0xf7, 0xda, 0x21, 0xff}
+	outerColor  = color.RGBA{0xe6, 0xe6, 0xe6, 0xff}
+	letterColor = color.RGBA{0x00, 0x00, 0x00, 0xff}
+)
+
+// runRender draws the puzzle hive for -letters.
+func runRender(args []string) error {
+	if *renderLetters == "" {
+		return fmt.Errorf("render: -letters is required")
+	}
+	out := *renderOut
+	if out == "" {
+		out = *renderLetters + ".png"
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, renderHive(*renderLetters, *renderSize)); err != nil {
+		f.Close()
+		return err
+	}
+	slog.Info("Wrote image", "file", out)
+	return f.Close()
+}
+
+// renderHive draws letters as a honeycomb: the first letter in a highlighted
+// hexagon in the middle, the rest in a ring around it.
+func renderHive(letters string, size int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	rs := []rune(letters)
+	if len(rs) == 0 {
+		return img
+	}
+	c := float64(size) / 2
+	r := float64(size) / 6.5
+	drawHex(img, c, c, r, centerColor)
+	drawGlyph(img, c, c, r, rs[0])
+	for i, l := range rs[1:] {
+		// Neighbouring flat-topped hexagons sit sqrt(3)*r apart; the extra 5%
+		// leaves a gap between cells.
+		a := -math.Pi/2 + 2*math.Pi*float64(i)/float64(len(rs)-1)
+		x := c + math.Sqrt(3)*r*1.05*math.Cos(a)
+		y := c + math.Sqrt(3)*r*1.05*math.Sin(a)
+		drawHex(img, x, y, r, outerColor)
+		drawGlyph(img, x, y, r, l)
+	}
+	return img
+}
+
+// drawHex fills a flat-topped hexagon with circumradius r centered on (cx, cy).
+func drawHex(img *image.RGBA, cx, cy, r float64, col color.Color) {
+	h := math.Sqrt(3) / 2 * r
+	for y := int(cy - h); y <= int(cy+h); y++ {
+		for x := int(cx - r); x <= int(cx+r); x++ {
+			dx, dy := math.Abs(float64(x)-cx), math.Abs(float64(y)-cy)
+			if dy <= h && math.Sqrt(3)*dx+dy <= math.Sqrt(3)*r {
+				img.Set(x, y, col)
+			}
+		}
+	}
+}
+
+// drawGlyph draws l in uppercase, scaled to fit a hexagon of circumradius r
+// centered on (cx, cy). Letters without a glyph are skipped.
+func drawGlyph(img *image.RGBA, cx, cy, r float64, l rune) {
+	l = unicode.ToLower(l)
+	if l < 'a' || l > 'z' {
+		return
+	}
+	g := glyphs[l-'a']
+	scale := int(r / 10)
+	if scale < 1 {
+		scale = 1
+	}
+	x0 := int(cx) - len(g[0])*scale/2
+	y0 := int(cy) - len(g)*scale/2
+	for gy, row := range g {
+		for gx, px := range row {
+			if px != '#' {
+				continue
+			}
+			for y := 0; y < scale; y++ {
+				for x := 0; x < scale; x++ {
+					img.Set(x0+gx*scale+x, y0+gy*scale+y, letterColor)
+				}
+			}
+		}
+	}
+}
+
+// glyphs is a 5x7 bitmap font for the uppercase letters A-Z.
+var glyphs = [26][7]string{
+	{".###.", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	{"####.", "#...#", "#...#", "####.", "#...#", "#...#", "####."},
+	{".###.", "#...#", "#....", "#....", "#....", "#...#", ".###."},
+	{"####.", "#...#", "#...#", "#...#", "#...#", "#...#", "####."},
+	{"#####", "#....", "#....", "####.", "#....", "#....", "#####"},
+	{"#####", "#....", "#....", "####.", "#....", "#....", "#...."},
+	{".###.", "#...#", "#....", "#.###", "#...#", "#...#", ".###."},
+	{"#...#", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	{".###.", "..#..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	{"..###", "...#.", "...#.", "...#.", "...#.", "#..#.", ".##.."},
+	{"#...#", "#..#.", "#.#..", "##...", "#.#..", "#..#.", "#...#"},
+	{"#....", "#....", "#....", "#....", "#....", "#....", "#####"},
+	{"#...#", "##.##", "#.#.#", "#.#.#", "#...#", "#...#", "#...#"},
+	{"#...#", "#...#", "##..#", "#.#.#", "#..##", "#...#", "#...#"},
+	{".###.", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	{"####.", "#...#", "#...#", "####.", "#....", "#....", "#...."},
+	{".###.", "#...#", "#...#", "#...#", "#.#.#", "#..#.", ".##.#"},
+	{"####.", "#...#", "#...#", "####.", "#.#..", "#..#.", "#...#"},
+	{".####", "#....", "#....", ".###.", "....#", "....#", "####."},
+	{"#####", "..#..", "..#..", "..#..", "..#..", "..#..", "..#.."},
+	{"#...#", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	{"#...#", "#...#", "#...#", "#...#", "#...#", ".#.#.", "..#.."},
+	{"#...#", "#...#", "#...#", "#.#.#", "#.#.#", "#.#.#", ".#.#."},
+	{"#...#", "#...#", ".#.#.", "..#..", ".#.#.", "#...#", "#...#"},
+	{"#...#", "#...#", ".#.#.", "..#..", "..#..", "..#..", "..#.."},
+	{"#####", "....#", "...#.", "..#..", ".#...", "#....", "#####"},
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// letterTally counts how often each letter is the center or an outer letter
+// of a puzzle.
+type letterTally struct {
+	center, outer map[rune]int
+}
+
+func tallyLetters(in <-chan puzzle) letterTally {
+	t := letterTally{center: map[rune]int{}, outer: map[rune]int{}}
+	for p := range in {
+		for i, l := range p.letters {
+			if i < len(centerLetter(p.letters)) {
+				t.center[l]++
+			} else {
+				t.outer[l]++
+			}
+		}
+	}
+	return t
+}
+
+// report writes a table of every letter of the alphabet, least used first.
+func (t letterTally) report(w io.Writer) error {
+	ls := []rune(opts.Alphabet)
+	total := func(l rune) int { return t.center[l] + t.outer[l] }
+	sort.SliceStable(ls, func(i, j int) bool { return total(ls[i]) < total(ls[j]) })
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "letter\tcenter\touter\ttotal\t")
+	for _, l := range ls {
+		fmt.Fprintf(tw, "%c\t%d\t%d\t%d\t\n", l, t.center[l], t.outer[l], total(l))
+	}
+	return tw.Flush()
+}
+
+// report writes a table of how many words each filter rejected.
+func (s LoadStats) report(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "words\tcount\t")
+	for _, r := range []struct {
+		name  string
+		count int
+	}{
+		{"read", s.Read},
+		{"too short", s.TooShort},
+		{"too long", s.TooLong},
+		{"not in alphabet", s.NotInAlphabet},
+		{"too many letters", s.TooManyLetters},
+		{"too few letters", s.TooFewLetters},
+		{"duplicates", s.Duplicates},
+		{"excluded", s.Excluded},
+		{"kept", s.Kept},
+	} {
+		fmt.Fprintf(tw, "%s\t%d\t\n", r.name, r.count)
+	}
+	return tw.Flush()
+}
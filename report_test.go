@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestTallyLettersTinyAlphabet(t *testing.T) {
+	o := DefaultOptions()
+	o.Alphabet, o.NumLetters, o.MinWordLen, o.MinWords = "abcde", 3, 3, 1
+	setOpts(t, o)
+	setFlag(t, wordsFile, writeTestFile(t, "dict.txt", "abc", "cab", "dab"))
+	setFlag(t, parallel, 2)
+	var tally letterTally
+	err := generate(context.Background(), nil, func(in <-chan puzzle) { tally = tallyLetters(in) })
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	// Letter sets abc and abd make a puzzle with each of their letters as the
+	// center: abc, bac, cab, abd, bad and dab.
+	for _, tc := range []struct {
+		l             rune
+		center, outer int
+	}{
+		{'a', 2, 4}, {'b', 2, 4}, {'c', 1, 2}, {'d', 1, 2}, {'e', 0, 0},
+	} {
+		if c, o := tally.center[tc.l], tally.outer[tc.l]; c != tc.center || o != tc.outer {
+			t.Errorf("%c is the center of %d puzzles and outer in %d, want %d and %d", tc.l, c, o, tc.center, tc.outer)
+		}
+	}
+
+	var b strings.Builder
+	if err := tally.report(&b); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(b.String(), "\n")
+	if len(lines) < 3 || !strings.HasPrefix(strings.TrimSpace(lines[1]), "e ") || !strings.HasPrefix(strings.TrimSpace(lines[2]), "c ") {
+		t.Errorf("report is:\n%s\nwant the least used letters, e then c, first", b.String())
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+var rescoreFlags = flag.NewFlagSet("rescore", flag.ContinueOnError)
+
+// runRescore re-scores the NDJSON puzzles on stdin and writes them to stdout.
+func runRescore(args []string) error {
+	return rescore(os.Stdin, os.Stdout)
+}
+
+// rescore reads one JSON puzzle per line from r, each with at least "letters"
+// (center letter first) and "words", and writes it to w with "maxPoints" and
+// "ranks" recomputed by ScoreWord. Other fields are passed through unchanged.
+func rescore(r io.Reader, w io.Writer) error {
+	dec := json.NewDecoder(r)
+	enc := json.NewEncoder(w)
+	for n := 1; ; n++ {
+		var rec map[string]json.RawMessage
+		if err := dec.Decode(&rec); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("puzzle %d: %v", n, err)
+		}
+		if rec["letters"] == nil || rec["words"] == nil {
+			return fmt.Errorf("puzzle %d: want letters and words", n)
+		}
+		var letters string
+		var words []string
+		if err := json.Unmarshal(rec["letters"], &letters); err != nil {
+			return fmt.Errorf("puzzle %d: letters: %v", n, err)
+		}
+		if err := json.Unmarshal(rec["words"], &words); err != nil {
+			return fmt.Errorf("puzzle %d: words: %v", n, err)
+		}
+
+		maxPts := 0
+		for _, w := range words {
+			maxPts += ScoreWord(w, letters)
+		}
+		var err error
+		if rec["maxPoints"], err = json.Marshal(maxPts); err != nil {
+			return err
+		}
+		if rec["ranks"], err = json.Marshal(ranks(maxPts)); err != nil {
+			return err
+		}
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+}
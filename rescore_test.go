@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/nickgraffis/spelling-bee/spellingbeetest"
+)
+
+func TestRescoreRoundTrip(t *testing.T) {
+	sp := spellingbeetest.SamplePuzzle()
+	letters, words, points := sp.Letters, sp.Words, sp.MaxPoints
+	in, err := json.Marshal(map[string]any{
+		"letters":   letters,
+		"words":     words,
+		"maxPoints": 1,
+		"source":    "elsewhere",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := rescore(bytes.NewReader(in), &out); err != nil {
+		t.Fatalf("rescore: %v", err)
+	}
+	var got struct {
+		Letters   string   `json:"letters"`
+		Words     []string `json:"words"`
+		MaxPoints int      `json:"maxPoints"`
+		Ranks     []rank   `json:"ranks"`
+		Source    string   `json:"source"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("rescore wrote %q: %v", out.String(), err)
+	}
+	if got.Letters != letters || len(got.Words) != len(words) || got.Source != "elsewhere" {
+		t.Errorf("rescore changed the puzzle's other fields: %+v", got)
+	}
+	if got.MaxPoints != points {
+		t.Errorf("rescore gave maxPoints %d, want %d", got.MaxPoints, points)
+	}
+	if n := len(got.Ranks); n != len(rankFractions) || got.Ranks[n-1].Points != points {
+		t.Errorf("rescore gave ranks %+v, want Queen Bee at %d points", got.Ranks, points)
+	}
+
+	// Rescoring the output again changes nothing.
+	var again bytes.Buffer
+	if err := rescore(bytes.NewReader(out.Bytes()), &again); err != nil {
+		t.Fatalf("rescore of its own output: %v", err)
+	}
+	if again.String() != out.String() {
+		t.Errorf("rescore of its own output = %q, want %q", again.String(), out.String())
+	}
+}
+
+func TestRescoreNeedsLettersAndWords(t *testing.T) {
+	err := rescore(strings.NewReader(`{"letters":"aelprst"}`), &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "puzzle 1") {
+		t.Errorf("rescore of a puzzle without words = %v, want an error for puzzle 1", err)
+	}
+}
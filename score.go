@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ScoreWord returns the points word earns in the puzzle made of letters.
+// Four-letter words earn -four_letter_score points (1 by default) and longer
+// words earn 1 point per letter, counting repeated letters each time.
+// Pangrams, which use every letter, earn a bonus on top: -pangram_bonus points
+// in "fixed" mode, or the word's length again in "length" mode. Perfect
+// pangrams, which use every letter exactly once, also earn
+// -perfect_pangram_bonus.
+//
+// If Options.ScoreExpr is set, it decides the points instead; see
+// parseScoreExpr.
+func ScoreWord(word, letters string) int {
+	n := utf8.RuneCountInString(word)
+	if opts.ScoreExpr != "" {
+		return scoreExprFor(opts.ScoreExpr)(&scoreVars{
+			len:              n,
+			isPangram:        bool2int(isPangram(word, letters)),
+			isPerfectPangram: bool2int(isPerfectPangram(word, letters)),
+			numLetters:       opts.NumLetters,
+		})
+	}
+	pts := n
+	if n == 4 {
+		pts = opts.FourLetterScore
+	}
+	if isPangram(word, letters) {
+		switch opts.PangramBonusMode {
+		case "length":
+			pts += n
+		default:
+			pts += fixedPangramBonus()
+		}
+		if isPerfectPangram(word, letters) {
+			pts += opts.PerfectPangramBonus
+		}
+	}
+	return pts
+}
+
+// CheckWord returns why word isn't a valid answer in the puzzle made of
+// letters, center letters first, or nil if it is: it must be -min_word_len
+// to -max_word_len letters long, use only letters, use the center letters
+// unless -require_center=false, and use at least -min_distinct_in_word
+// different letters. Whether word is in the dictionary isn't checked.
+func CheckWord(word, letters string) error {
+	n := utf8.RuneCountInString(word)
+	switch {
+	case n < opts.MinWordLen:
+		return fmt.Errorf("%q has %d letters, want at least %d", word, n, opts.MinWordLen)
+	case opts.MaxWordLen != 0 && n > opts.MaxWordLen:
+		return fmt.Errorf("%q has %d letters, want at most %d", word, n, opts.MaxWordLen)
+	case !containsOnly(word, letters):
+		return fmt.Errorf("%q uses letters outside %q", word, letters)
+	case opts.RequireCenter && !isPangram(word, centerLetter(letters)):
+		return fmt.Errorf("%q doesn't use center letter %q", word, centerLetter(letters))
+	case opts.MinDistinctLetters > 0 && hasAtMostLetters(word, opts.Alphabet, opts.MinDistinctLetters-1):
+		return fmt.Errorf("%q uses fewer than %d different letters", word, opts.MinDistinctLetters)
+	}
+	return nil
+}
+
+// fixedPangramBonus returns -pangram_bonus, which defaults to -num_letters.
+func fixedPangramBonus() int {
+	if opts.PangramBonus < 0 {
+		return opts.NumLetters
+	}
+	return opts.PangramBonus
+}
+
+// validatePangramBonusMode checks the -pangram_bonus_mode flag.
+func validatePangramBonusMode(mode string) error {
+	switch mode {
+	case "fixed", "length":
+		return nil
+	}
+	return fmt.Errorf("unknown pangram bonus mode %q, want fixed or length", mode)
+}
+
+// isPerfectPangram reports whether word uses every one of letters exactly
+// once.
+func isPerfectPangram(word, letters string) bool {
+	return utf8.RuneCountInString(word) == utf8.RuneCountInString(letters) && isPangram(word, letters)
+}
+
+// isPangram reports whether word uses every one of letters, however many
+// times each.
+func isPangram(word, letters string) bool {
+	for _, l := range letters {
+		if !strings.ContainsRune(word, l) {
+			return false
+		}
+	}
+	return true
+}
+
+// isNearPangram reports whether word uses all but one of letters.
+func isNearPangram(word, letters string) bool {
+	missing := 0
+	for _, l := range letters {
+		if !strings.ContainsRune(word, l) {
+			missing++
+		}
+	}
+	return missing == 1
+}
+
+// nearPangrams returns the words in words that are near pangrams of letters,
+// or nil if there are none.
+func nearPangrams(words []string, letters string) []string {
+	var near []string
+	for _, w := range words {
+		if isNearPangram(w, letters) {
+			near = append(near, w)
+		}
+	}
+	return near
+}
+
+// A rank is a title a player earns on reaching Points in a puzzle.
+type rank struct {
+	Name   string `json:"name"`
+	Points int    `json:"points"`
+}
+
+// rankFractions lists the Spelling Bee ranks and the fraction of a puzzle's
+// maximum points each one requires.
+var rankFractions = []struct {
+	name     string
+	fraction float64
+}{
+	{"Beginner", 0},
+	{"Good Start", 0.02},
+	{"Moving Up", 0.05},
+	{"Good", 0.08},
+	{"Solid", 0.15},
+	{"Nice", 0.25},
+	{"Great", 0.40},
+	{"Amazing", 0.50},
+	{"Genius", 0.70},
+	{"Queen Bee", 1},
+}
+
+// ranks returns the point threshold of every rank for a puzzle worth maxPts.
+func ranks(maxPts int) []rank {
+	rs := make([]rank, len(rankFractions))
+	for i, r := range rankFractions {
+		rs[i] = rank{Name: r.name, Points: int(math.Round(r.fraction * float64(maxPts)))}
+	}
+	return rs
+}
+
+// A WordScore is an answer's points and the running total of points when the
+// answers are taken from highest scoring to lowest.
+type WordScore struct {
+	Word       string `json:"word"`
+	Points     int    `json:"points"`
+	Cumulative int    `json:"cumulative"`
+	// Rank names the highest rank the running total reaches with this word,
+	// if it reaches one it hadn't before.
+	Rank string `json:"rank,omitempty"`
+}
+
+// wordScores scores each of words in the puzzle made of letters and returns
+// them sorted by points, highest first, with running totals.
+func wordScores(words []string, letters string) []WordScore {
+	scores := make([]WordScore, len(words))
+	maxPts := 0
+	for i, w := range words {
+		scores[i] = WordScore{Word: w, Points: ScoreWord(w, letters)}
+		maxPts += scores[i].Points
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Points != scores[j].Points {
+			return scores[i].Points > scores[j].Points
+		}
+		return scores[i].Word < scores[j].Word
+	})
+	rs := ranks(maxPts)
+	total := 0
+	for i := range scores {
+		prev := total
+		total += scores[i].Points
+		scores[i].Cumulative = total
+		for _, r := range rs {
+			if prev < r.Points && r.Points <= total {
+				scores[i].Rank = r.Name
+			}
+		}
+	}
+	return scores
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/nickgraffis/spelling-bee/spellingbeetest"
+)
+
+func TestScoreWordPangramBonusModes(t *testing.T) {
+	for _, tc := range []struct {
+		mode  string
+		bonus int
+		word  string
+		want  int
+	}{
+		{"fixed", -1, "plasters", 8 + 7},
+		{"fixed", 2, "plasters", 8 + 2},
+		{"length", -1, "plasters", 8 + 8},
+		{"length", 2, "plasters", 8 + 8},
+		{"fixed", 2, "plate", 5},
+		{"length", -1, "plate", 5},
+	} {
+		o := DefaultOptions()
+		o.PangramBonusMode, o.PangramBonus = tc.mode, tc.bonus
+		setOpts(t, o)
+		if got := ScoreWord(tc.word, "aelprst"); got != tc.want {
+			t.Errorf("%s mode, bonus %d: ScoreWord(%q) = %d, want %d", tc.mode, tc.bonus, tc.word, got, tc.want)
+		}
+	}
+}
+
+func TestScoreWordFourLetterScore(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	if got := ScoreWord("peal", "aelprst"); got != 1 {
+		t.Errorf("by default, ScoreWord(peal) = %d, want 1", got)
+	}
+	o := DefaultOptions()
+	o.FourLetterScore = 4
+	setOpts(t, o)
+	if got := ScoreWord("peal", "aelprst"); got != 4 {
+		t.Errorf("with -four_letter_score 4, ScoreWord(peal) = %d, want 4", got)
+	}
+	if got := ScoreWord("plate", "aelprst"); got != 5 {
+		t.Errorf("with -four_letter_score 4, ScoreWord(plate) = %d, want 5", got)
+	}
+}
+
+func TestWordScoresCumulativeAndRanks(t *testing.T) {
+	want := []WordScore{
+		{"plaster", 14, 14, "Solid"},
+		{"plates", 6, 20, "Nice"},
+		{"alert", 5, 25, ""},
+		{"apple", 5, 30, "Great"},
+		{"areal", 5, 35, "Amazing"},
+		{"leapt", 5, 40, ""},
+		{"pasta", 5, 45, ""},
+		{"petal", 5, 50, "Genius"},
+		{"plate", 5, 55, ""},
+		{"pleat", 5, 60, ""},
+		{"sepal", 5, 65, ""},
+		{"tapas", 5, 70, "Queen Bee"},
+	}
+	got := wordScores(spellingbeetest.SamplePuzzle().Words, "aelprst")
+	if len(got) != len(want) {
+		t.Fatalf("wordScores returned %d scores, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("wordScores()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRepeatedLetterPangrams(t *testing.T) {
+	o := DefaultOptions()
+	o.NumLetters, o.MinWords = 6, 4
+	setOpts(t, o)
+	const letters = "kbeopr"
+	for _, tc := range []struct {
+		word             string
+		pangram, perfect bool
+		points           int
+	}{
+		{"bookkeeper", true, false, 10 + 6},
+		{"bookkeep", false, false, 8},
+		{"booker", false, false, 6},
+		{"probed", false, false, 6}, // d isn't one of the letters
+	} {
+		if got := isPangram(tc.word, letters); got != tc.pangram {
+			t.Errorf("isPangram(%q, %q) = %t, want %t", tc.word, letters, got, tc.pangram)
+		}
+		if got := isPerfectPangram(tc.word, letters); got != tc.perfect {
+			t.Errorf("isPerfectPangram(%q, %q) = %t, want %t", tc.word, letters, got, tc.perfect)
+		}
+		if got := ScoreWord(tc.word, letters); got != tc.points {
+			t.Errorf("ScoreWord(%q, %q) = %d, want %d", tc.word, letters, got, tc.points)
+		}
+	}
+
+	idx := testIndex(t, "bookkeeper", "booker", "keeper", "poker", "probed")
+	p, ok := makePuzzle(idx, letters)
+	if !ok {
+		t.Fatalf("makePuzzle(%q) made no puzzle", letters)
+	}
+	// bookkeeper 16, booker 6, keeper 6 and poker 5.
+	if p.maxPts != 33 {
+		t.Errorf("makePuzzle(%q) gave %q %d points, want 33", letters, p.words, p.maxPts)
+	}
+}
+
+func TestPerfectPangramBonus(t *testing.T) {
+	o := DefaultOptions()
+	o.PerfectPangramBonus = 3
+	setOpts(t, o)
+	for _, tc := range []struct {
+		word    string
+		perfect bool
+		points  int
+	}{
+		{"plaster", true, 7 + 7 + 3},
+		{"plasters", false, 8 + 7},
+		{"plate", false, 5},
+	} {
+		if got := isPerfectPangram(tc.word, "aelprst"); got != tc.perfect {
+			t.Errorf("isPerfectPangram(%q) = %t, want %t", tc.word, got, tc.perfect)
+		}
+		if got := ScoreWord(tc.word, "aelprst"); got != tc.points {
+			t.Errorf("with -perfect_pangram_bonus 3, ScoreWord(%q) = %d, want %d", tc.word, got, tc.points)
+		}
+	}
+}
+
+func TestNearPangrams(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	sp := samplePuzzle()
+	want := []string{"plates"}
+	if got := nearPangrams(sp.words, sp.letters); !slices.Equal(got, want) {
+		t.Errorf("nearPangrams of the sample puzzle = %q, want %q", got, want)
+	}
+	if r := newPuzzleRecord(sp); !slices.Equal(r.NearPangrams, want) {
+		t.Errorf("the sample puzzle's record has near pangrams %q, want %q", r.NearPangrams, want)
+	}
+
+	o := DefaultOptions()
+	o.RequireNearPangram = true
+	setOpts(t, o)
+	if _, reason := checkPuzzle(testIndex(t, spellingbeetest.SampleDictionary()...), "aelprst"); reason != "" {
+		t.Errorf("with -require_near_pangram, checkPuzzle(aelprst) rejected it: %s", reason)
+	}
+	words := slices.DeleteFunc(spellingbeetest.SampleDictionary(), func(w string) bool { return w == "plates" })
+	if _, reason := checkPuzzle(testIndex(t, words...), "aelprst"); reason != "no near pangram" {
+		t.Errorf("with -require_near_pangram and no plates, checkPuzzle(aelprst) gave reason %q, want \"no near pangram\"", reason)
+	}
+}
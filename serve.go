@@ -0,0 +1,332 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"expvar"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+var serveFlags = flag.NewFlagSet("serve", flag.ContinueOnError)
+
+var (
+	addr      = serveFlags.String("addr", ":8080", "Address to serve the puzzle API on")
+	cacheSize = serveFlags.Int("cache_size", 1024, "Number of puzzles to keep in memory for repeated requests (0 disables the cache)")
+)
+
+// runServe serves puzzles over HTTP, answering /healthz with 503 until the
+// dictionary has loaded. Runtime metrics are served as JSON at /debug/vars.
+// On SIGHUP it reloads the dictionary from -words_file.
+func runServe(args []string) error {
+	if *cacheSize < 0 {
+		return fmt.Errorf("-cache_size is %d, want at least 0", *cacheSize)
+	}
+	s := &server{cache: newPuzzleCache(*cacheSize)}
+	go func() {
+		if err := s.reload(*wordsFile); err != nil {
+			log.Fatal(err)
+		}
+		slog.Info("Ready")
+	}()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go s.reloadOn(hup, *wordsFile)
+
+	slog.Info("Serving", "addr", *addr)
+	return http.ListenAndServe(*addr, s.handler())
+}
+
+// server answers puzzle API requests from an in-memory dictionary.
+type server struct {
+	mu    sync.RWMutex // guards idx, and keeps cache in step with it
+	idx   *wordIndex   // nil until the dictionary has loaded
+	cache *puzzleCache
+}
+
+// ready reports whether the server has loaded its dictionary.
+func (s *server) ready() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.idx != nil
+}
+
+// reload replaces the server's dictionary with the one at path and clears
+// its cache. On error the old dictionary is kept.
+func (s *server) reload(path string) error {
+	idx, err := loadIndex(path)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.idx = idx
+	s.cache.clear()
+	return nil
+}
+
+// reloadOn reloads the dictionary from path each time a signal arrives on
+// sig, until sig is closed.
+func (s *server) reloadOn(sig <-chan os.Signal, path string) {
+	for range sig {
+		if err := s.reload(path); err != nil {
+			// Keep serving the dictionary we have.
+			slog.Error("Reload failed", "err", err)
+			continue
+		}
+		slog.Info("Reloaded dictionary", "file", path)
+	}
+}
+
+// puzzle returns makePuzzle(s.idx, letters), from the cache if it has been
+// built before.
+func (s *server) puzzle(letters string) (puzzle, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key := canonicalLetters(letters)
+	if p, ok, found := s.cache.get(key); found {
+		return p, ok
+	}
+	p, ok := makePuzzle(s.idx, letters)
+	s.cache.add(key, p, ok)
+	return p, ok
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/puzzle/", s.handlePuzzle)
+	mux.HandleFunc("/score", s.handleScore)
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestsServed.Add(1)
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// puzzleResponse is the JSON form of a puzzle returned by the API.
+type puzzleResponse struct {
+	puzzleRecord
+
+	// Total is the number of answers, however many Words holds.
+	Total int `json:"total"`
+	// Scores is only set when requested with ?scores=true.
+	Scores []WordScore `json:"scores,omitempty"`
+}
+
+// A page is the part of a list selected by the limit and offset query
+// parameters. A zero limit means no limit.
+type page struct {
+	limit, offset int
+}
+
+func parsePage(q url.Values) (page, error) {
+	var pg page
+	for _, p := range []struct {
+		name string
+		v    *int
+	}{{"limit", &pg.limit}, {"offset", &pg.offset}} {
+		s := q.Get(p.name)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return page{}, fmt.Errorf("%s is %q, want a number at least 0", p.name, s)
+		}
+		*p.v = n
+	}
+	return pg, nil
+}
+
+// bounds returns the slice bounds of the page in a list of n items.
+func (pg page) bounds(n int) (lo, hi int) {
+	lo, hi = n, n
+	if pg.offset < n {
+		lo = pg.offset
+	}
+	if pg.limit > 0 && lo+pg.limit < n {
+		hi = lo + pg.limit
+	}
+	return lo, hi
+}
+
+// puzzleID identifies p by its letters, answers and points, so it changes if
+// the dictionary or scoring options do.
+func puzzleID(p puzzle) string {
+	h := sha256.New()
+	fmt.Fprintln(h, p.letters, p.maxPts)
+	for _, w := range p.words {
+		fmt.Fprintln(h, w)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:12])
+}
+
+// etagMatches reports whether an If-None-Match header value lists etag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == etag || t == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// errorResponse is the JSON body of an API error.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// jsonError replies to a request with the HTTP status code and a JSON body
+// giving msg as the error.
+func jsonError(w http.ResponseWriter, msg string, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(errorResponse{Error: msg})
+}
+
+// handleHealthz serves GET /healthz: 200 once the dictionary has loaded, and
+// 503 before then.
+func (s *server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if !s.ready() {
+		http.Error(w, "dictionary is loading", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// handlePuzzle serves GET /puzzle/{letters}, where letters has the center
+// letter first. Errors, such as 400 Bad Request for a malformed letter set,
+// have a JSON body with the reason as "error". With ?scores=true the response also lists each answer's
+// points and running total, highest scoring first. ?limit=N&offset=M return
+// only answers M to M+N-1 of the words (and scores) lists. Responses carry an
+// ETag for the puzzle and the page and scores asked for, and a request whose
+// If-None-Match lists it gets 304 Not Modified.
+func (s *server) handlePuzzle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.ready() {
+		jsonError(w, "dictionary is loading", http.StatusServiceUnavailable)
+		return
+	}
+	letters := strings.TrimPrefix(r.URL.Path, "/puzzle/")
+	if err := validateLetters(letters, opts.NumLetters); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	pg, err := parsePage(r.URL.Query())
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p, ok := s.puzzle(letters)
+	if !ok {
+		jsonError(w, fmt.Sprintf("letter set %q does not make a valid puzzle", letters), http.StatusNotFound)
+		return
+	}
+	lo, hi := pg.bounds(len(p.words))
+	scores, _ := strconv.ParseBool(r.URL.Query().Get("scores"))
+	// The body depends on the page and scores as well as the puzzle, so each
+	// gets its own ETag.
+	etag := fmt.Sprintf(`"%s-%d-%d-%t"`, puzzleID(p), lo, hi, scores)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	resp := puzzleResponse{puzzleRecord: newPuzzleRecord(p), Total: len(p.words)}
+	resp.Words = resp.Words[lo:hi]
+	if scores {
+		resp.Scores = wordScores(p.words, p.letters)[lo:hi]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// scoreRequest is the JSON body of POST /score: a letter set, center letter
+// first, and the words to score with it.
+type scoreRequest struct {
+	Letters string   `json:"letters"`
+	Words   []string `json:"words"`
+}
+
+// scoredWord is one word of a scoreResponse. Points is 0 for invalid words,
+// and for repeats of a word listed earlier.
+type scoredWord struct {
+	Word   string `json:"word"`
+	Valid  bool   `json:"valid"`
+	Points int    `json:"points"`
+	// Error is why the word isn't valid, if it isn't.
+	Error string `json:"error,omitempty"`
+}
+
+// scoreResponse is the JSON response to POST /score.
+type scoreResponse struct {
+	Letters string       `json:"letters"`
+	Center  string       `json:"center"`
+	Words   []scoredWord `json:"words"`
+	// Answers is the number of distinct valid words.
+	Answers   int `json:"answers"`
+	MaxPoints int `json:"maxPoints"`
+}
+
+// maxScoreRequest is the largest POST /score body accepted, in bytes.
+const maxScoreRequest = 1 << 20
+
+// handleScore serves POST /score, which scores a client's own word list for
+// a letter set instead of using the dictionary: each word is checked with
+// CheckWord and valid ones scored with ScoreWord, in the order given. A word
+// listed twice only counts once. Errors have a JSON body like handlePuzzle's.
+func (s *server) handleScore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req scoreRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxScoreRequest))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		jsonError(w, fmt.Sprintf("bad request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := validateLetters(req.Letters, opts.NumLetters); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp := scoreResponse{Letters: req.Letters, Center: centerLetter(req.Letters), Words: []scoredWord{}}
+	seen := map[string]bool{}
+	for _, word := range req.Words {
+		sw := scoredWord{Word: word}
+		switch err := CheckWord(word, req.Letters); {
+		case err != nil:
+			sw.Error = err.Error()
+		case seen[word]:
+			sw.Valid = true
+		default:
+			seen[word] = true
+			sw.Valid = true
+			sw.Points = ScoreWord(word, req.Letters)
+			resp.Answers++
+			resp.MaxPoints += sw.Points
+		}
+		resp.Words = append(resp.Words, sw)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
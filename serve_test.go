@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nickgraffis/spelling-bee/spellingbeetest"
+)
+
+// get serves a GET of target from s, with If-None-Match set if etag isn't
+// empty.
+func get(s *server, target, etag string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	if etag != "" {
+		r.Header.Set("If-None-Match", etag)
+	}
+	w := httptest.NewRecorder()
+	s.handler().ServeHTTP(w, r)
+	return w
+}
+
+func TestServerCachesPuzzles(t *testing.T) {
+	s := &server{idx: sampleIndex(t), cache: newPuzzleCache(4)}
+	generated := puzzlesGenerated.Value()
+	for _, target := range []string{"/puzzle/aelprst", "/puzzle/atsrple"} {
+		if w := get(s, target, ""); w.Code != http.StatusOK {
+			t.Fatalf("GET %s: status %d, body %s", target, w.Code, w.Body)
+		}
+	}
+	if got := puzzlesGenerated.Value() - generated; got != 1 {
+		t.Errorf("two requests for the same letters built %d puzzles, want 1", got)
+	}
+
+	if err := s.reload(writeTestFile(t, "dict.txt", spellingbeetest.SampleDictionary()...)); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	get(s, "/puzzle/aelprst", "")
+	if got := puzzlesGenerated.Value() - generated; got != 2 {
+		t.Errorf("a request after reloading built %d puzzles in all, want 2", got)
+	}
+}
+
+func TestSIGHUPReloadsDictionary(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	path := writeTestFile(t, "dict.txt", slices.DeleteFunc(spellingbeetest.SampleDictionary(), func(w string) bool { return w == "plates" })...)
+	s := &server{cache: newPuzzleCache(4)}
+	if err := s.reload(path); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	hasPlates := func() bool {
+		var resp puzzleResponse
+		if err := json.Unmarshal(get(s, "/puzzle/aelprst", "").Body.Bytes(), &resp); err != nil {
+			t.Fatalf("GET /puzzle/aelprst: %v", err)
+		}
+		return slices.Contains(resp.Words, "plates")
+	}
+	if hasPlates() {
+		t.Fatal("plates is an answer before it is added to the dictionary")
+	}
+
+	if err := os.WriteFile(path, []byte(strings.Join(spellingbeetest.SampleDictionary(), "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	hup := make(chan os.Signal, 1)
+	defer close(hup)
+	go s.reloadOn(hup, path)
+	hup <- syscall.SIGHUP
+	for deadline := time.Now().Add(5 * time.Second); !hasPlates(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("plates isn't an answer 5s after SIGHUP")
+		}
+	}
+}
+
+func TestHealthzReadyAfterLoad(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	s := &server{cache: newPuzzleCache(0)}
+	for _, target := range []string{"/healthz", "/puzzle/aelprst"} {
+		if got := get(s, target, "").Code; got != http.StatusServiceUnavailable {
+			t.Errorf("GET %s while loading: status %d, want 503", target, got)
+		}
+	}
+	if err := s.reload(writeTestFile(t, "dict.txt", spellingbeetest.SampleDictionary()...)); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	for _, target := range []string{"/healthz", "/puzzle/aelprst"} {
+		if got := get(s, target, "").Code; got != http.StatusOK {
+			t.Errorf("GET %s once loaded: status %d, want 200", target, got)
+		}
+	}
+}
+
+func TestPuzzlePaging(t *testing.T) {
+	s := &server{idx: sampleIndex(t), cache: newPuzzleCache(0)}
+	for _, tc := range []struct {
+		query  string
+		words  []string
+		scores []string
+	}{
+		{"limit=3&offset=2", []string{"tapas", "areal", "alert"}, nil},
+		{"offset=10", []string{"plaster", "plates"}, nil},
+		{"offset=20", []string{}, nil},
+		{"limit=2&scores=true", []string{"apple", "pasta"}, []string{"plaster", "plates"}},
+	} {
+		w := get(s, "/puzzle/aelprst?"+tc.query, "")
+		var resp puzzleResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("GET ?%s: status %d, %v", tc.query, w.Code, err)
+		}
+		scores := []string{}
+		for _, sc := range resp.Scores {
+			scores = append(scores, sc.Word)
+		}
+		if !slices.Equal(resp.Words, tc.words) || resp.Total != 12 || (tc.scores != nil && !slices.Equal(scores, tc.scores)) {
+			t.Errorf("GET ?%s: words %q, scores %q, total %d; want %q, %q, 12", tc.query, resp.Words, scores, resp.Total, tc.words, tc.scores)
+		}
+	}
+	for _, query := range []string{"limit=-1", "offset=x"} {
+		if got := get(s, "/puzzle/aelprst?"+query, "").Code; got != http.StatusBadRequest {
+			t.Errorf("GET ?%s: status %d, want 400", query, got)
+		}
+	}
+}
+
+func TestPuzzleIfNoneMatch(t *testing.T) {
+	s := &server{idx: sampleIndex(t), cache: newPuzzleCache(0)}
+	etag := get(s, "/puzzle/aelprst", "").Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("GET /puzzle/aelprst sent no ETag")
+	}
+	for _, tc := range []struct {
+		ifNoneMatch string
+		want        int
+	}{
+		{etag, http.StatusNotModified},
+		{"W/" + etag, http.StatusNotModified},
+		{`"other", ` + etag, http.StatusNotModified},
+		{"*", http.StatusNotModified},
+		{`"other"`, http.StatusOK},
+	} {
+		if got := get(s, "/puzzle/aelprst", tc.ifNoneMatch).Code; got != tc.want {
+			t.Errorf("GET with If-None-Match %s: status %d, want %d", tc.ifNoneMatch, got, tc.want)
+		}
+	}
+}
+
+func TestPuzzleETagVariesWithPageAndScores(t *testing.T) {
+	s := &server{idx: sampleIndex(t), cache: newPuzzleCache(0)}
+	first := get(s, "/puzzle/aelprst?limit=2", "")
+	if first.Code != http.StatusOK {
+		t.Fatalf("GET ?limit=2: status %d, body %s", first.Code, first.Body)
+	}
+	etag := first.Header().Get("ETag")
+	if got := get(s, "/puzzle/aelprst?limit=2", etag).Code; got != http.StatusNotModified {
+		t.Errorf("GET ?limit=2 with its own ETag: status %d, want 304", got)
+	}
+	for _, target := range []string{
+		"/puzzle/aelprst",
+		"/puzzle/aelprst?limit=2&offset=2",
+		"/puzzle/aelprst?limit=2&scores=true",
+	} {
+		if got := get(s, target, etag).Code; got != http.StatusOK {
+			t.Errorf("GET %s with the ETag of ?limit=2: status %d, want 200", target, got)
+		}
+	}
+}
+
+func TestPuzzleRejectsBadLetters(t *testing.T) {
+	s := &server{idx: sampleIndex(t), cache: newPuzzleCache(0)}
+	for letters, want := range map[string]string{
+		"aelprs":       "has 6 letters, want 7",
+		"aelprstx":     "has 8 letters, want 7",
+		"aelprs1":      "must contain only letters",
+		"Aelprst":      "must contain only letters",
+		"aelprsa":      "repeated letters",
+		"aelprs%C3%A9": "must contain only letters",
+	} {
+		w := get(s, "/puzzle/"+letters, "")
+		var body errorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Errorf("GET /puzzle/%s: body %q isn't JSON: %v", letters, w.Body, err)
+			continue
+		}
+		if w.Code != http.StatusBadRequest || !strings.Contains(body.Error, want) {
+			t.Errorf("GET /puzzle/%s: status %d, error %q; want 400 and an error with %q", letters, w.Code, body.Error, want)
+		}
+	}
+}
+
+func TestScoreEndpoint(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	s := &server{cache: newPuzzleCache(0)}
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/score", strings.NewReader(body)))
+		return w
+	}
+	w := post(`{"letters": "aelprst", "words": ["plaster", "plate", "trees", "lap", "plate", "zebra"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /score: status %d, body %s", w.Code, w.Body)
+	}
+	var got scoreResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []scoredWord{
+		{Word: "plaster", Valid: true, Points: 14},
+		{Word: "plate", Valid: true, Points: 5},
+		{Word: "trees", Error: `"trees" doesn't use center letter "a"`},
+		{Word: "lap", Error: `"lap" has 3 letters, want at least 5`},
+		{Word: "plate", Valid: true},
+		{Word: "zebra", Error: `"zebra" uses letters outside "aelprst"`},
+	}
+	if !slices.Equal(got.Words, want) {
+		t.Errorf("POST /score words = %+v, want %+v", got.Words, want)
+	}
+	if got.Center != "a" || got.Answers != 2 || got.MaxPoints != 19 {
+		t.Errorf("POST /score: center %q, %d answers worth %d, want a, 2 and 19", got.Center, got.Answers, got.MaxPoints)
+	}
+
+	for body, code := range map[string]int{
+		`{"letters": "aelprs", "words": []}`: http.StatusBadRequest,
+		`{"letters": "aelprst", "word": []}`: http.StatusBadRequest,
+		`not json`:                           http.StatusBadRequest,
+	} {
+		if w := post(body); w.Code != code {
+			t.Errorf("POST /score %s: status %d, want %d", body, w.Code, code)
+		}
+	}
+	if w := get(s, "/score", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /score: status %d, want 405", w.Code)
+	}
+}
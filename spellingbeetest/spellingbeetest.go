@@ -0,0 +1,46 @@
+// Package spellingbeetest provides fixtures for testing code that reads the
+// puzzles spelling-bee writes, such as its -format json output or the
+// /puzzle/ API.
+package spellingbeetest
+
+// Puzzle is a puzzle as spelling-bee writes it in JSON.
+type Puzzle struct {
+	Letters        string   `json:"letters"`
+	Center         string   `json:"center"`
+	Outer          []string `json:"outer"`
+	Words          []string `json:"words"`
+	MaxPoints      int      `json:"maxPoints"`
+	LongestWordLen int      `json:"longestWordLen"`
+	NearPangrams   []string `json:"nearPangrams,omitempty"`
+}
+
+// SampleDictionary returns a small dictionary, one word per element. Besides
+// the answers of SamplePuzzle, it has words that aren't answers: "trees"
+// lacks the center letter, "zebra" uses other letters and "lap" is too
+// short.
+func SampleDictionary() []string {
+	return []string{
+		"apple", "pasta", "tapas", "areal", "alert", "pleat", "plate",
+		"petal", "leapt", "sepal", "plaster", "plates", "trees", "zebra",
+		"lap",
+	}
+}
+
+// SamplePuzzle returns the puzzle spelling-bee makes from SampleDictionary
+// for the letters "aelprst" with the default options: ten five-letter
+// answers worth 5 points each, the pangram "plaster" worth 7 plus a 7 point
+// bonus, and "plates" worth 6.
+func SamplePuzzle() Puzzle {
+	return Puzzle{
+		Letters: "aelprst",
+		Center:  "a",
+		Outer:   []string{"e", "l", "p", "r", "s", "t"},
+		Words: []string{
+			"apple", "pasta", "tapas", "areal", "alert", "pleat", "plate",
+			"petal", "leapt", "sepal", "plaster", "plates",
+		},
+		MaxPoints:      70,
+		LongestWordLen: 7,
+		NearPangrams:   []string{"plates"},
+	}
+}
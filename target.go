@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"sort"
+)
+
+// sampleAnswerCounts returns the number of answers of every sampleEvery'th
+// letter set generate would try that makes a puzzle when any number of
+// answers is allowed, ignoring -resume and -old_words_file. With
+// -best_center, the best center's count is used.
+func sampleAnswerCounts(ctx context.Context, idx *wordIndex, sampleEvery int) ([]int, error) {
+	sets, err := letterSets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	minWords := opts.MinWords
+	opts.MinWords = 1
+	defer func() { opts.MinWords = minWords }()
+
+	counts := []int{}
+	i := 0
+	for s := range sets {
+		i++
+		if (i-1)%sampleEvery != 0 {
+			continue
+		}
+		choices := []string{s}
+		if *bestCenter {
+			choices = centerChoices(s)
+		}
+		n := -1
+		for _, c := range choices {
+			// checkPuzzle, unlike makePuzzle, leaves the metrics alone.
+			p, reason := checkPuzzle(idx, c)
+			if reason == "" && finalCheck(p) == "" {
+				n = max(n, len(p.words))
+			}
+		}
+		if n >= 0 {
+			counts = append(counts, n)
+		}
+	}
+	return counts, ctx.Err()
+}
+
+// targetMinWords returns the -min_words that would let about target puzzles
+// through, given the answer counts of every sampleEvery'th candidate. It is
+// at least 1; if fewer than target puzzles can be made at all, it is the
+// smallest sampled count.
+func targetMinWords(counts []int, sampleEvery, target int) int {
+	if len(counts) == 0 {
+		return 1
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(counts)))
+	k := (target + sampleEvery/2) / sampleEvery
+	k = max(1, min(k, len(counts)))
+	return max(1, counts[k-1])
+}
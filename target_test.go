@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTargetMinWords(t *testing.T) {
+	counts := []int{4, 30, 12, 8, 20, 15, 10, 6}
+	for _, tc := range []struct{ every, target, want int }{
+		{1, 1, 30},
+		{1, 3, 15},
+		{1, 8, 4},
+		{1, 100, 4},
+		{10, 30, 15},
+		{10, 4, 30},
+	} {
+		if got := targetMinWords(append([]int{}, counts...), tc.every, tc.target); got != tc.want {
+			t.Errorf("targetMinWords(%v, %d, %d) = %d, want %d", counts, tc.every, tc.target, got, tc.want)
+		}
+	}
+	if got := targetMinWords(nil, 1, 5); got != 1 {
+		t.Errorf("targetMinWords with no counts = %d, want 1", got)
+	}
+}
+
+func TestTargetPuzzlesThreshold(t *testing.T) {
+	idx := sampleIndex(t)
+	setFlag(t, letters, "")
+	// With the sample dictionary these make 12, 11, 10 and 4 answers.
+	setFlag(t, lettersFile, writeTestFile(t, "sets.txt", "aelprst", "eaplrst", "paelrst", "raelpst"))
+	counts, err := sampleAnswerCounts(context.Background(), idx, 1)
+	if err != nil {
+		t.Fatalf("sampleAnswerCounts: %v", err)
+	}
+	for target, want := range map[int]int{1: 12, 2: 11, 3: 10, 4: 4} {
+		n := targetMinWords(append([]int{}, counts...), 1, target)
+		if n != want {
+			t.Errorf("for %d puzzles, -min_words %d, want %d", target, n, want)
+		}
+		o := DefaultOptions()
+		o.MinWords = n
+		setOpts(t, o)
+		if got := len(matchAll(idx, "aelprst", "eaplrst", "paelrst", "raelpst")); got != target {
+			t.Errorf("for %d puzzles, -min_words %d made %d", target, n, got)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// puzzleHeap is a min-heap of puzzles by points, so the least valuable of
+// the best K puzzles seen so far can be dropped when a better one arrives.
+type puzzleHeap []puzzle
+
+func (h puzzleHeap) Len() int { return len(h) }
+func (h puzzleHeap) Less(i, j int) bool {
+	if h[i].maxPts != h[j].maxPts {
+		return h[i].maxPts < h[j].maxPts
+	}
+	return h[i].letters > h[j].letters
+}
+func (h puzzleHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *puzzleHeap) Push(x any)   { *h = append(*h, x.(puzzle)) }
+func (h *puzzleHeap) Pop() any {
+	old := *h
+	p := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return p
+}
+
+// topPerCenter reads every puzzle from in, keeping only the k with the most
+// points for each center letter, and returns a channel of them, by center
+// letter and then highest scoring first. Ties go to the alphabetically
+// first letters.
+func topPerCenter(in <-chan puzzle, k int) <-chan puzzle {
+	out := make(chan puzzle)
+	go func() {
+		defer close(out)
+		heaps := map[string]*puzzleHeap{}
+		for p := range in {
+			c := centerLetter(p.letters)
+			h := heaps[c]
+			if h == nil {
+				h = &puzzleHeap{}
+				heaps[c] = h
+			}
+			heap.Push(h, p)
+			if h.Len() > k {
+				heap.Pop(h)
+			}
+		}
+
+		centers := make([]string, 0, len(heaps))
+		for c := range heaps {
+			centers = append(centers, c)
+		}
+		sort.Strings(centers)
+		for _, c := range centers {
+			h := heaps[c]
+			best := make([]puzzle, h.Len())
+			for i := len(best) - 1; i >= 0; i-- {
+				best[i] = heap.Pop(h).(puzzle)
+			}
+			for _, p := range best {
+				out <- p
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTopPerCenter(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	// Puzzles worth 1 to 5 points centered on a, 1 to 3 on b and 1 on c.
+	in := centerPuzzles(map[string]int{"a": 5, "b": 3, "c": 1})
+	got := []string{}
+	for p := range topPerCenter(in, 2) {
+		got = append(got, p.letters)
+	}
+	want := []string{"a000005", "a000004", "b000003", "b000002", "c000001"}
+	if !slices.Equal(got, want) {
+		t.Errorf("topPerCenter(2) = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// LoadTSVDictionary is like LoadDictionary, but each line holds a word and
+// an integer weight, such as its quality in a curated list, separated by a
+// tab. It also returns the weight of each word kept.
+func LoadTSVDictionary(r io.Reader, opts Options) (words []string, weights map[string]float64, stats LoadStats, err error) {
+	words = []string{}
+	weights = map[string]float64{}
+	err = eachLine(r, func(line int, l string) error {
+		w, weight, ok := strings.Cut(l, "\t")
+		if !ok {
+			return fmt.Errorf("line %d: want a word, a tab and its weight, got %q", line, l)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(weight))
+		if err != nil {
+			return fmt.Errorf("line %d: bad weight %q for %q", line, weight, w)
+		}
+		if w, ok := stats.filter(strings.TrimSpace(w), opts); ok {
+			words = append(words, w)
+			if _, found := weights[w]; !found {
+				weights[w] = float64(n)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, stats, err
+	}
+	words, stats, err = stats.kept(words)
+	return words, weights, stats, err
+}
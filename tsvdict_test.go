@@ -0,0 +1,42 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTSVDictionary(t *testing.T) {
+	setOpts(t, DefaultOptions())
+	words, weights, _, err := LoadTSVDictionary(strings.NewReader("plate\t900\napple\t40\nlap\t7\nplate\t1\n"), opts)
+	if err != nil {
+		t.Fatalf("LoadTSVDictionary: %v", err)
+	}
+	if !slices.Equal(words, []string{"plate", "apple"}) {
+		t.Errorf("LoadTSVDictionary kept %q, want plate and apple", words)
+	}
+	if want := map[string]float64{"plate": 900, "apple": 40}; !maps.Equal(weights, want) {
+		t.Errorf("LoadTSVDictionary weights = %v, want %v", weights, want)
+	}
+	for _, bad := range []string{"plate 900\n", "plate\tmany\n"} {
+		if _, _, _, err := LoadTSVDictionary(strings.NewReader(bad), opts); err == nil || !strings.Contains(err.Error(), "line 1") {
+			t.Errorf("LoadTSVDictionary(%q) = %v, want an error for line 1", bad, err)
+		}
+	}
+
+	// A TSV -words_file's weights are used as frequencies, for difficulty.
+	setFlag(t, dictFormat, "auto")
+	setFlag(t, excludeWordsFile, "")
+	setFlag(t, freqFile, "")
+	idx, err := loadIndex(writeTestFile(t, "dict.tsv", "plate\t900", "apple\t500", "pasta\t40"))
+	if err != nil {
+		t.Fatalf("loadIndex: %v", err)
+	}
+	if d := idx.difficulty("plate"); d != "common" {
+		t.Errorf("difficulty of the most heavily weighted word = %q, want common", d)
+	}
+	if d := idx.difficulty("pasta"); d != "rare" {
+		t.Errorf("difficulty of the least heavily weighted word = %q, want rare", d)
+	}
+}
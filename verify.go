@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var verifyFlags = flag.NewFlagSet("verify", flag.ContinueOnError)
+
+// runVerify checks every txt puzzle in -out_dir and fails if any is wrong.
+func runVerify(args []string) error {
+	problems, err := verifyDir(*outDir, os.Stdout)
+	if err != nil {
+		return err
+	}
+	if problems > 0 {
+		return fmt.Errorf("found %d problems in %s", problems, *outDir)
+	}
+	return nil
+}
+
+// verifyDir checks every .txt puzzle file under dir, printing each problem
+// to w, and returns the number of problems found.
+func verifyDir(dir string, w io.Writer) (int, error) {
+	problems := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".txt" {
+			return nil
+		}
+		ps, err := verifyFile(path)
+		if err != nil {
+			return err
+		}
+		for _, p := range ps {
+			fmt.Fprintf(w, "%s: %s\n", path, p)
+		}
+		problems += len(ps)
+		return nil
+	})
+	return problems, err
+}
+
+// verifyFile checks that every answer in the txt puzzle at path is playable
+// with the letters in its file name, and that its points line matches the
+// answers. It returns a description of each problem found.
+func verifyFile(path string) ([]string, error) {
+	letters := strings.TrimSuffix(filepath.Base(path), ".txt")
+	if err := validateLetters(letters, opts.NumLetters); err != nil {
+		return []string{err.Error()}, nil
+	}
+	words, last, problems, err := readTxtPuzzle(path, letters)
+	if err != nil || words == nil {
+		return problems, err
+	}
+	pts := 0
+	for _, w := range words {
+		// Answers may have been written with -output_case upper.
+		w = strings.ToLower(w)
+		switch {
+		case !isPangram(w, centerLetter(letters)):
+			problems = append(problems, fmt.Sprintf("%q doesn't use center letter %q", w, centerLetter(letters)))
+		case !containsOnly(w, letters):
+			problems = append(problems, fmt.Sprintf("%q uses letters outside %q", w, letters))
+		}
+		pts += ScoreWord(w, letters)
+	}
+	want, err := strconv.Atoi(last)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("last line %q is not a point total", last))
+	} else if want != pts {
+		problems = append(problems, fmt.Sprintf("file says %d points, answers score %d", want, pts))
+	}
+	return problems, nil
+}
+
+// readTxtPuzzle reads the txt puzzle at path for the letter set letters,
+// written with or without -txt_header, -score_first and -mark_pangrams. It
+// returns the answers and the line that should hold the points, and a
+// description of each problem with the header. If the file is empty, words
+// is nil.
+func readTxtPuzzle(path, letters string) (words []string, points string, problems []string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	defer f.Close()
+	lines := []string{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		return nil, "", nil, err
+	}
+	if len(lines) == 0 {
+		return nil, "", []string{"empty file"}, nil
+	}
+
+	problems = []string{}
+	// Check and skip the header written by -txt_header, if any.
+	for len(lines) > 1 {
+		want := ""
+		key, val, _ := strings.Cut(lines[0], ": ")
+		switch key {
+		case "center":
+			want = centerLetter(letters)
+		case "letters":
+			want = letters
+		}
+		if want == "" {
+			break
+		}
+		if strings.ToLower(val) != want {
+			problems = append(problems, fmt.Sprintf("header says %s %q, file name says %q", key, val, want))
+		}
+		lines = lines[1:]
+	}
+	words, points = lines[:len(lines)-1], lines[len(lines)-1]
+	// Files written with -score_first start with the point total instead.
+	if _, err := strconv.Atoi(lines[0]); err == nil && len(lines) > 1 {
+		if _, err := strconv.Atoi(points); err != nil {
+			words, points = lines[1:], lines[0]
+		}
+	}
+	// Drop -mark_pangrams marks.
+	for i, w := range words {
+		words[i], _, _ = strings.Cut(w, " ")
+	}
+	return words, points, problems, nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyDirFlagsTamperedFile(t *testing.T) {
+	dir := writeFormat(t, "txt", samplePuzzle())
+	var out strings.Builder
+	if n, err := verifyDir(dir, &out); err != nil || n != 0 {
+		t.Fatalf("verifyDir of a fresh puzzle = %d, %v, want no problems; printed:\n%s", n, err, out.String())
+	}
+
+	path := filepath.Join(dir, "aelprst.txt")
+	b := readOutput(t, dir, "aelprst.txt")
+	if err := os.WriteFile(path, []byte(strings.Replace(string(b), "apple\n", "zebra\n", 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	n, err := verifyDir(dir, &out)
+	if err != nil {
+		t.Fatalf("verifyDir: %v", err)
+	}
+	if n == 0 || !strings.Contains(out.String(), `"zebra" uses letters outside`) {
+		t.Errorf("verifyDir of a tampered puzzle found %d problems, printing:\n%s\nwant zebra flagged", n, out.String())
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	rdebug "runtime/debug"
+)
+
+var versionFlags = flag.NewFlagSet("version", flag.ContinueOnError)
+
+// runVersion prints the version and build information of this binary.
+func runVersion(args []string) error {
+	return printVersion(os.Stdout)
+}
+
+// printVersion writes the module version, VCS revision and Go version this
+// binary was built from to w. Fields the build didn't record are left out.
+func printVersion(w io.Writer) error {
+	info, ok := rdebug.ReadBuildInfo()
+	if !ok {
+		return fmt.Errorf("no build information in this binary")
+	}
+	fmt.Fprintf(w, "%s %s\n", info.Main.Path, info.Main.Version)
+	settings := map[string]string{}
+	for _, s := range info.Settings {
+		settings[s.Key] = s.Value
+	}
+	if rev := settings["vcs.revision"]; rev != "" {
+		if settings["vcs.modified"] == "true" {
+			rev += " (modified)"
+		}
+		fmt.Fprintf(w, "revision %s\n", rev)
+	}
+	if t := settings["vcs.time"]; t != "" {
+		fmt.Fprintf(w, "committed %s\n", t)
+	}
+	_, err := fmt.Fprintf(w, "built with %s\n", info.GoVersion)
+	return err
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	var err error
+	stdout, _ := captureOutput(t, func() { err = run([]string{"version"}) })
+	if err != nil {
+		t.Fatalf("version: %v", err)
+	}
+	if !strings.Contains(stdout, "built with "+runtime.Version()) {
+		t.Errorf("version printed %q, want the Go version, %s", stdout, runtime.Version())
+	}
+}